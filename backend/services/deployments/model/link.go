@@ -0,0 +1,27 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import "time"
+
+// Link is a signed request for accessing an object in the storage backend
+// directly, bypassing the deployments service for the data transfer itself.
+type Link struct {
+	Uri    string            `json:"uri" bson:"uri"`
+	Method string            `json:"method" bson:"method"`
+	Header map[string]string `json:"header,omitempty" bson:"header,omitempty"`
+
+	ExpireTime time.Time `json:"expire,omitempty" bson:"expire,omitempty"`
+}
@@ -0,0 +1,88 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+// StorageType enumerates the object storage backends supported by the
+// deployments service.
+type StorageType string
+
+const (
+	StorageTypeS3    StorageType = "s3"
+	StorageTypeAzure StorageType = "azure"
+	StorageTypeGCS   StorageType = "gcs"
+)
+
+// StorageSettings holds per-tenant object storage configuration that can be
+// threaded through a request context, overriding the globally configured
+// storage backend.
+type StorageSettings struct {
+	Type StorageType `json:"type" bson:"type"`
+
+	Region string `json:"region,omitempty" bson:"region,omitempty"`
+	Bucket string `json:"bucket" bson:"bucket"`
+
+	Key    string `json:"key,omitempty" bson:"key,omitempty"`
+	Secret string `json:"secret,omitempty" bson:"secret,omitempty"`
+	Token  string `json:"token,omitempty" bson:"token,omitempty"`
+
+	Uri            string `json:"uri,omitempty" bson:"uri,omitempty"`
+	ForcePathStyle *bool  `json:"force_path_style,omitempty" bson:"force_path_style,omitempty"`
+	UseAccelerate  *bool  `json:"use_accelerate,omitempty" bson:"use_accelerate,omitempty"`
+
+	ConnectionString *string `json:"connection_string,omitempty" bson:"connection_string,omitempty"`
+
+	// CredentialsJSON holds a raw GCP service-account JSON key, consulted
+	// by the gcs storage backend when Type is StorageTypeGCS. When nil,
+	// the backend authenticates using Application Default Credentials.
+	CredentialsJSON *string `json:"credentials_json,omitempty" bson:"credentials_json,omitempty"`
+
+	// Azure holds Azure AD credential parameters consulted by the azblob
+	// storage backend when Type is StorageTypeAzure and neither a shared
+	// key nor a connection string is configured.
+	Azure *AzureSettings `json:"azure,omitempty" bson:"azure,omitempty"`
+
+	// RootDirectory is prepended to every object key by the azblob storage
+	// backend, letting a single container be shared across multiple
+	// tenants or environments without collisions. The gcs backend does not
+	// read it.
+	RootDirectory string `json:"root_directory,omitempty" bson:"root_directory,omitempty"`
+
+	Tenant string `json:"tenant,omitempty" bson:"tenant,omitempty"`
+}
+
+// AzureCredentialType selects the Azure AD authentication mode used by the
+// azblob storage backend.
+type AzureCredentialType string
+
+const (
+	AzureCredentialTypeClientSecret     AzureCredentialType = "client_secret"
+	AzureCredentialTypeManagedIdentity  AzureCredentialType = "managed_identity"
+	AzureCredentialTypeWorkloadIdentity AzureCredentialType = "workload_identity"
+)
+
+// AzureSettings holds the Azure AD credential parameters for the azblob
+// storage backend's token-based authentication modes (client secret,
+// managed identity, and workload identity federation via
+// DefaultAzureCredential).
+type AzureSettings struct {
+	CredentialType AzureCredentialType `json:"credential_type,omitempty" bson:"credential_type,omitempty"`
+
+	TenantID string `json:"tenant_id,omitempty" bson:"tenant_id,omitempty"`
+	ClientID string `json:"client_id,omitempty" bson:"client_id,omitempty"`
+
+	// ClientSecret is only used when CredentialType is
+	// AzureCredentialTypeClientSecret.
+	ClientSecret string `json:"client_secret,omitempty" bson:"client_secret,omitempty"`
+}
@@ -16,6 +16,7 @@ package azblob
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"io"
 	"net"
@@ -23,14 +24,18 @@ import (
 	"net/http/httptest"
 	"os"
 	"path"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/service"
 	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -330,6 +335,411 @@ func TestKeyFromConnectionString(t *testing.T) {
 	}
 }
 
+func TestContainerClientAndNameUsesPerRequestContainer(t *testing.T) {
+	t.Parallel()
+	connStr := "AccountName=foobar;AccountKey=Zm9vYmFy"
+
+	c, err := New(
+		context.Background(), "default-container", NewOptions().SetConnectionString(connStr),
+	)
+	assert.NoError(t, err)
+
+	// containerClientAndName (and hence delegationSignedURL, which builds
+	// the signed URL from its result) must resolve the container named by
+	// the per-request settings in ctx, not the one baked into
+	// c.DefaultClient at construction time.
+	ctx := storage.SettingsWithContext(context.Background(), &model.StorageSettings{
+		Type:             model.StorageTypeAzure,
+		Bucket:           "tenant-container",
+		ConnectionString: &connStr,
+	})
+	cc, name, err := c.containerClientAndName(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "tenant-container", name)
+	assert.Contains(t, cc.URL(), "tenant-container")
+	assert.NotEqual(t, c.DefaultClient.URL(), cc.URL())
+}
+
+func TestOptionsFromSettings(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		Name string
+
+		Settings *model.StorageSettings
+		Error    error
+	}{{
+		Name: "ok/connection string",
+
+		Settings: &model.StorageSettings{
+			Bucket:           "container",
+			ConnectionString: func() *string { s := "AccountName=foo;AccountKey=Zm9v"; return &s }(),
+		},
+	}, {
+		Name: "ok/shared key",
+
+		Settings: &model.StorageSettings{
+			Bucket: "container",
+			Key:    "foo",
+			Secret: "Zm9v",
+		},
+	}, {
+		Name: "ok/client secret credential",
+
+		Settings: &model.StorageSettings{
+			Bucket: "container",
+			Uri:    "https://foo.blob.core.windows.net",
+			Azure: &model.AzureSettings{
+				CredentialType: model.AzureCredentialTypeClientSecret,
+				TenantID:       "tenant",
+				ClientID:       "client",
+				ClientSecret:   "secret",
+			},
+		},
+	}, {
+		Name: "ok/managed identity",
+
+		Settings: &model.StorageSettings{
+			Bucket: "container",
+			Uri:    "https://foo.blob.core.windows.net",
+			Azure: &model.AzureSettings{
+				CredentialType: model.AzureCredentialTypeManagedIdentity,
+			},
+		},
+	}, {
+		Name: "ok/workload identity",
+
+		Settings: &model.StorageSettings{
+			Bucket: "container",
+			Uri:    "https://foo.blob.core.windows.net",
+			Azure: &model.AzureSettings{
+				CredentialType: model.AzureCredentialTypeWorkloadIdentity,
+			},
+		},
+	}, {
+		Name: "error/missing bucket",
+
+		Settings: &model.StorageSettings{},
+		Error:    validation.Errors{},
+	}, {
+		Name: "error/no credentials configured",
+
+		Settings: &model.StorageSettings{Bucket: "container"},
+		Error:    ErrNoCredentials,
+	}, {
+		Name: "error/unknown azure credential type",
+
+		Settings: &model.StorageSettings{
+			Bucket: "container",
+			Azure:  &model.AzureSettings{CredentialType: "bogus"},
+		},
+		Error: ErrNoCredentials,
+	}}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+
+			o, err := optionsFromSettings(tc.Settings)
+			if tc.Error != nil {
+				assert.Error(t, err)
+				if !errors.Is(tc.Error, ErrNoCredentials) {
+					var verr validation.Errors
+					assert.ErrorAs(t, err, &verr)
+				} else {
+					assert.ErrorIs(t, err, tc.Error)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.NotNil(t, o)
+		})
+	}
+}
+
+func TestServiceClientForUsesPerRequestAccount(t *testing.T) {
+	t.Parallel()
+
+	c, err := New(
+		context.Background(), "default-container",
+		NewOptions().SetURI("https://default.blob.core.windows.net").
+			SetClientSecretCredential("default-tenant", "default-client", "default-secret"),
+	)
+	assert.NoError(t, err)
+
+	// serviceClientFor (and hence delegationSignedURL, which fetches its
+	// delegation key through its result) must resolve the service client
+	// for the account named by the per-request settings in ctx, not the
+	// one baked into c.serviceClient at construction time - a
+	// user-delegation key is only valid for the account that issued it.
+	ctx := storage.SettingsWithContext(context.Background(), &model.StorageSettings{
+		Type:   model.StorageTypeAzure,
+		Bucket: "tenant-container",
+		Uri:    "https://tenant.blob.core.windows.net",
+		Azure: &model.AzureSettings{
+			CredentialType: model.AzureCredentialTypeClientSecret,
+			TenantID:       "tenant",
+			ClientID:       "client-id",
+			ClientSecret:   "client-secret",
+		},
+	})
+	svc, err := c.serviceClientFor(ctx)
+	assert.NoError(t, err)
+	assert.Contains(t, svc.URL(), "tenant.blob.core.windows.net")
+	assert.NotEqual(t, c.serviceClient.URL(), svc.URL())
+}
+
+// fakeTokenCredential hands out a canned token without making a network
+// call, letting tests exercise the token-credential signing path against a
+// local httptest.Server.
+type fakeTokenCredential struct{}
+
+func (fakeTokenCredential) GetToken(
+	context.Context, policy.TokenRequestOptions,
+) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "fake-token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+const fakeDelegationKeyXML = `<?xml version="1.0" encoding="utf-8"?>
+<UserDelegationKey>
+	<SignedOid>00000000-0000-0000-0000-000000000000</SignedOid>
+	<SignedTid>00000000-0000-0000-0000-000000000000</SignedTid>
+	<SignedStart>2024-01-01T00:00:00Z</SignedStart>
+	<SignedExpiry>2024-01-01T01:00:00Z</SignedExpiry>
+	<SignedService>b</SignedService>
+	<SignedVersion>2021-08-06</SignedVersion>
+	<Value>ZmFrZWtleQ==</Value>
+</UserDelegationKey>`
+
+func TestHealthCheckDoesNotPerturbDelegationCache(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("comp") == "userdelegationkey" {
+			w.Header().Set("Content-Type", "application/xml")
+			_, _ = w.Write([]byte(fakeDelegationKeyXML))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	var d net.Dialer
+	azOpts := azcore.ClientOptions{
+		InsecureAllowCredentialWithHTTP: true,
+		Transport: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+					return d.DialContext(
+						ctx, srv.Listener.Addr().Network(), srv.Listener.Addr().String(),
+					)
+				},
+			},
+		},
+	}
+	cred := fakeTokenCredential{}
+	cc, err := container.NewClient(
+		srv.URL+"/container", cred, &container.ClientOptions{ClientOptions: azOpts},
+	)
+	assert.NoError(t, err)
+	svc, err := service.NewClient(srv.URL, cred, &service.ClientOptions{ClientOptions: azOpts})
+	assert.NoError(t, err)
+
+	c := &client{
+		DefaultClient: cc,
+		credential:    cred,
+		serviceClient: svc,
+	}
+
+	// HealthCheck's own healthCheckTimeout (5s) is far shorter than any
+	// real signed-URL request's duration; if it went through the shared
+	// cache, it would both populate it and reschedule the background
+	// refresh timer around that short duration.
+	err = c.HealthCheck(context.Background())
+	assert.NoError(t, err)
+
+	c.delegation.mu.Lock()
+	entries := len(c.delegation.entries)
+	c.delegation.mu.Unlock()
+	assert.Equal(t, 0, entries, "HealthCheck must not populate the shared delegation cache")
+}
+
+// newTestDelegationServerAndClient wires up a client whose container and
+// service clients both talk to srv over plain HTTP via a fake token
+// credential, letting tests exercise the user-delegation signing path
+// without a real Azure AD/blob-service endpoint.
+func newTestDelegationServerAndClient(handler http.HandlerFunc) (*client, *httptest.Server) {
+	srv := httptest.NewServer(handler)
+	var d net.Dialer
+	azOpts := azcore.ClientOptions{
+		InsecureAllowCredentialWithHTTP: true,
+		Transport: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+					return d.DialContext(
+						ctx, srv.Listener.Addr().Network(), srv.Listener.Addr().String(),
+					)
+				},
+			},
+		},
+	}
+	cred := fakeTokenCredential{}
+	cc, err := container.NewClient(
+		srv.URL+"/container", cred, &container.ClientOptions{ClientOptions: azOpts},
+	)
+	if err != nil {
+		srv.Close()
+		panic(err)
+	}
+	svc, err := service.NewClient(srv.URL, cred, &service.ClientOptions{ClientOptions: azOpts})
+	if err != nil {
+		srv.Close()
+		panic(err)
+	}
+	return &client{
+		DefaultClient: cc,
+		container:     "container",
+		credential:    cred,
+		serviceClient: svc,
+	}, srv
+}
+
+func TestDelegationSignedURL(t *testing.T) {
+	t.Parallel()
+
+	c, srv := newTestDelegationServerAndClient(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("comp") == "userdelegationkey" {
+			w.Header().Set("Content-Type", "application/xml")
+			_, _ = w.Write([]byte(fakeDelegationKeyXML))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	defer srv.Close()
+
+	link, err := c.GetRequest(context.Background(), "foo/bar", "bar.mender", time.Minute, true)
+	// GetRequest also calls GetProperties against the fake server, which
+	// the handler above answers with a bare 200 - enough for
+	// delegationSignedURL's own behavior, which is what this test targets.
+	assert.NoError(t, err)
+	assert.Contains(t, link.Uri, "foo/bar")
+	assert.Contains(t, link.Uri, "skoid=") // signed using a user-delegation key
+	assert.Equal(t, "GET", link.Method)
+}
+
+func TestWithRootDirectory(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		Name string
+
+		ClientRootDirectory string
+		CTX                 context.Context
+		Path                string
+
+		Expected string
+	}{{
+		Name: "ok/no root directory",
+
+		Path:     "foo/bar",
+		Expected: "foo/bar",
+	}, {
+		Name: "ok/client root directory",
+
+		ClientRootDirectory: "tenant-a",
+		Path:                "foo/bar",
+		Expected:            "tenant-a/foo/bar",
+	}, {
+		Name: "ok/per-request root directory overrides client's",
+
+		ClientRootDirectory: "tenant-a",
+		CTX: storage.SettingsWithContext(context.Background(), &model.StorageSettings{
+			RootDirectory: "tenant-b",
+		}),
+		Path:     "foo/bar",
+		Expected: "tenant-b/foo/bar",
+	}}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+
+			c := &client{rootDirectory: tc.ClientRootDirectory}
+			ctx := tc.CTX
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			assert.Equal(t, tc.Expected, c.withRootDirectory(ctx, tc.Path))
+		})
+	}
+}
+
+func TestClassifyHealthCheckError(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		Name string
+
+		Err      error
+		Expected error
+	}{{
+		Name: "nil",
+	}, {
+		Name: "container missing",
+
+		Err:      &azcore.ResponseError{ErrorCode: string(bloberror.ContainerNotFound)},
+		Expected: storage.ErrHealthCheckContainerMissing,
+	}, {
+		Name: "container being deleted",
+
+		Err:      &azcore.ResponseError{ErrorCode: string(bloberror.ContainerBeingDeleted)},
+		Expected: storage.ErrHealthCheckContainerMissing,
+	}, {
+		Name: "auth failed",
+
+		Err:      &azcore.ResponseError{ErrorCode: string(bloberror.AuthenticationFailed)},
+		Expected: storage.ErrHealthCheckAuthFailed,
+	}, {
+		Name: "authorization failure",
+
+		Err:      &azcore.ResponseError{ErrorCode: string(bloberror.AuthorizationFailure)},
+		Expected: storage.ErrHealthCheckAuthFailed,
+	}, {
+		Name: "insufficient permissions",
+
+		Err:      &azcore.ResponseError{ErrorCode: string(bloberror.InsufficientAccountPermissions)},
+		Expected: storage.ErrHealthCheckAuthFailed,
+	}, {
+		Name: "network error",
+
+		Err:      context.DeadlineExceeded,
+		Expected: storage.ErrHealthCheckNetwork,
+	}, {
+		Name: "unrecognized error passes through unchanged",
+
+		Err: errors.New("boom"),
+	}}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+
+			err := classifyHealthCheckError(tc.Err)
+			if tc.Err == nil {
+				assert.NoError(t, err)
+				return
+			}
+			if tc.Expected != nil {
+				assert.ErrorIs(t, err, tc.Expected)
+			}
+			assert.ErrorIs(t, err, tc.Err)
+		})
+	}
+}
+
 func newTestStorageAndServer(handler http.Handler) (*client, *httptest.Server) {
 	srv := httptest.NewServer(handler)
 	contentType := "application/vnd-test"
@@ -379,6 +789,65 @@ func newTestStorageAndServer(handler http.Handler) (*client, *httptest.Server) {
 	}, srv
 }
 
+// blockIDTagPattern extracts the block IDs listed in a CommitBlockList
+// request body, in the order they were sent.
+var blockIDTagPattern = regexp.MustCompile(`<Latest>([^<]+)</Latest>`)
+
+func TestCompleteMultipartUploadSortsByPartNumber(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		Name string
+
+		Parts []storage.CompletedPart
+		Error error
+	}{{
+		Name: "ok/already in order",
+
+		Parts: []storage.CompletedPart{{PartNumber: 1}, {PartNumber: 2}, {PartNumber: 3}},
+	}, {
+		Name: "ok/out of order",
+
+		Parts: []storage.CompletedPart{{PartNumber: 3}, {PartNumber: 1}, {PartNumber: 2}},
+	}, {
+		Name: "error/duplicate part number",
+
+		Parts: []storage.CompletedPart{{PartNumber: 1}, {PartNumber: 2}, {PartNumber: 1}},
+		Error: ErrDuplicatePartNumber,
+	}}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+
+			var committedIDs []string
+			azClient, srv := newTestStorageAndServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("comp") == "blocklist" {
+					body, _ := io.ReadAll(r.Body)
+					for _, match := range blockIDTagPattern.FindAllSubmatch(body, -1) {
+						committedIDs = append(committedIDs, string(match[1]))
+					}
+				}
+				w.WriteHeader(http.StatusCreated)
+			}))
+			defer srv.Close()
+
+			err := azClient.CompleteMultipartUpload(
+				context.Background(), "foo/bar", "upload-id", tc.Parts,
+			)
+			if tc.Error != nil {
+				assert.ErrorIs(t, err, tc.Error)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, []string{
+				blockID(1), blockID(2), blockID(3),
+			}, committedIDs, "blocks must be committed in ascending PartNumber order")
+		})
+	}
+}
+
 func TestGetObject(t *testing.T) {
 	t.Parallel()
 
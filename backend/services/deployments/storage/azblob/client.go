@@ -0,0 +1,526 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+// Package azblob implements storage.ObjectStorage on top of Azure Blob
+// Storage.
+package azblob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/sas"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/service"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+
+	"github.com/mendersoftware/mender-server/services/deployments/model"
+	"github.com/mendersoftware/mender-server/services/deployments/storage"
+)
+
+var (
+	ErrConnStrNoName = errors.New("azblob: connection string is missing AccountName")
+	ErrConnStrNoKey  = errors.New("azblob: connection string is missing AccountKey")
+
+	ErrNoCredentials = errors.New("azblob: no credentials configured: " +
+		"set a connection string, shared key, or Azure AD credential")
+	ErrNoURI = errors.New("azblob: URI is required when authenticating with a token credential")
+
+	ErrInvalidBufferSize = errors.New("azblob: buffer size is outside [BufferSizeMin, BufferSizeMax]")
+)
+
+// client implements storage.ObjectStorage on top of Azure Blob Storage.
+type client struct {
+	DefaultClient *container.Client
+	container     string
+
+	// credentials is set when authenticating with an account shared key;
+	// it is required to mint SAS tokens for the shared-key fallback path.
+	credentials *azblob.SharedKeyCredential
+	// credential is set when authenticating with an Azure AD token
+	// credential (client secret, managed identity, or
+	// DefaultAzureCredential / workload identity). When set, signed URLs
+	// prefer a user-delegation SAS over the (unavailable) shared-key SAS.
+	credential azcore.TokenCredential
+	// serviceClient is the blob-service-level client used to fetch user
+	// delegation keys; only set when credential is.
+	serviceClient *service.Client
+
+	delegation delegationCache
+
+	// rootDirectory, when set, is prepended to every object key so that a
+	// single container can be shared across multiple tenants/environments
+	// without collisions. A per-tenant override can be supplied via
+	// model.StorageSettings.RootDirectory in the request context.
+	rootDirectory string
+
+	contentType *string
+	bufferSize  int
+}
+
+// New initializes an azblob storage.ObjectStorage client for containerName.
+// If opts is empty, the client is initialized from the model.StorageSettings
+// found in ctx (see storage.SettingsWithContext); containerName still names
+// the container, and settings.Bucket is used only when containerName is
+// empty.
+func New(ctx context.Context, containerName string, opts ...*Options) (*client, error) {
+	var o *Options
+	if len(opts) > 0 && opts[0] != nil {
+		o = opts[0]
+	} else {
+		settings := storage.SettingsFromContext(ctx)
+		if settings == nil {
+			return nil, ErrNoCredentials
+		}
+		if containerName == "" {
+			containerName = settings.Bucket
+		}
+		var err error
+		o, err = optionsFromSettings(settings)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if o.err != nil {
+		return nil, o.err
+	}
+	if o.bufferSize != 0 && (o.bufferSize < BufferSizeMin || o.bufferSize > BufferSizeMax) {
+		return nil, ErrInvalidBufferSize
+	}
+
+	cc, sharedKey, err := newContainerClient(containerName, o)
+	if err != nil {
+		return nil, err
+	}
+
+	var svc *service.Client
+	if o.credential != nil && o.uri != nil {
+		svc, err = service.NewClient(*o.uri, o.credential, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	bufferSize := o.bufferSize
+	if bufferSize == 0 {
+		bufferSize = BufferSizeDefault
+	}
+	var rootDirectory string
+	if o.rootDirectory != nil {
+		rootDirectory = *o.rootDirectory
+	}
+	return &client{
+		DefaultClient: cc,
+		container:     containerName,
+		credentials:   sharedKey,
+		credential:    o.credential,
+		serviceClient: svc,
+		rootDirectory: rootDirectory,
+
+		contentType: o.contentType,
+		bufferSize:  bufferSize,
+	}, nil
+}
+
+// newContainerClient builds a container.Client for containerName from the
+// credential configured on o, trying connection string, shared key, and
+// token credential in that order.
+func newContainerClient(
+	containerName string, o *Options,
+) (*container.Client, *azblob.SharedKeyCredential, error) {
+	switch {
+	case o.connectionString != nil:
+		key, err := keyFromConnString(*o.connectionString)
+		if err != nil {
+			return nil, nil, err
+		}
+		cc, err := container.NewClientFromConnectionString(
+			*o.connectionString, containerName, nil,
+		)
+		return cc, key, err
+
+	case o.sharedKey != nil:
+		url, key, err := o.sharedKey.azParams(containerName)
+		if err != nil {
+			return nil, nil, err
+		}
+		cc, err := container.NewClientWithSharedKeyCredential(url, key, nil)
+		return cc, key, err
+
+	case o.credential != nil:
+		if o.uri == nil {
+			return nil, nil, ErrNoURI
+		}
+		url := strings.TrimSuffix(*o.uri, "/") + "/" + containerName
+		cc, err := container.NewClient(url, o.credential, nil)
+		return cc, nil, err
+
+	default:
+		return nil, nil, ErrNoCredentials
+	}
+}
+
+// optionsFromSettings derives storage Options from per-tenant
+// model.StorageSettings, selecting the Azure AD credential mode described by
+// settings.Azure when no shared key/connection string is present.
+func optionsFromSettings(settings *model.StorageSettings) (*Options, error) {
+	if err := validation.ValidateStruct(settings,
+		validation.Field(&settings.Bucket, validation.Required),
+	); err != nil {
+		return nil, err
+	}
+	o := NewOptions()
+	if settings.RootDirectory != "" {
+		o.SetRootDirectory(settings.RootDirectory)
+	}
+	switch {
+	case settings.ConnectionString != nil:
+		o.SetConnectionString(*settings.ConnectionString)
+	case settings.Key != "" && settings.Secret != "":
+		o.SetSharedKey(SharedKeyCredentials{
+			AccountName: settings.Key,
+			AccountKey:  settings.Secret,
+		})
+	case settings.Azure != nil:
+		if settings.Uri != "" {
+			o.SetURI(settings.Uri)
+		}
+		switch settings.Azure.CredentialType {
+		case model.AzureCredentialTypeClientSecret:
+			o.SetClientSecretCredential(
+				settings.Azure.TenantID,
+				settings.Azure.ClientID,
+				settings.Azure.ClientSecret,
+			)
+		case model.AzureCredentialTypeManagedIdentity:
+			o.SetManagedIdentity(settings.Azure.ClientID)
+		case model.AzureCredentialTypeWorkloadIdentity:
+			o.SetDefaultCredential()
+		default:
+			return nil, ErrNoCredentials
+		}
+	default:
+		return nil, ErrNoCredentials
+	}
+	return o, nil
+}
+
+// keyFromConnString extracts the shared-key credential embedded in an Azure
+// storage account connection string.
+func keyFromConnString(connStr string) (*azblob.SharedKeyCredential, error) {
+	var accountName, accountKey string
+	for _, attr := range strings.Split(connStr, ";") {
+		kv := strings.SplitN(attr, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "AccountName":
+			accountName = kv[1]
+		case "AccountKey":
+			accountKey = kv[1]
+		}
+	}
+	if accountName == "" {
+		return nil, ErrConnStrNoName
+	}
+	if accountKey == "" {
+		return nil, ErrConnStrNoKey
+	}
+	return azblob.NewSharedKeyCredential(accountName, accountKey)
+}
+
+// containerClient resolves the container.Client to use for a single
+// request: the per-tenant client described by the model.StorageSettings in
+// ctx, when present, or the DefaultClient configured at construction time.
+func (c *client) containerClient(ctx context.Context) (*container.Client, error) {
+	cc, _, err := c.containerClientAndName(ctx)
+	return cc, err
+}
+
+// containerClientAndName is like containerClient but also returns the
+// container name in effect for the request, needed to build SAS query
+// parameters for the user-delegation signing path.
+func (c *client) containerClientAndName(ctx context.Context) (*container.Client, string, error) {
+	settings := storage.SettingsFromContext(ctx)
+	if settings == nil {
+		return c.DefaultClient, c.container, nil
+	}
+	o, err := optionsFromSettings(settings)
+	if err != nil {
+		return nil, "", err
+	}
+	cc, _, err := newContainerClient(settings.Bucket, o)
+	return cc, settings.Bucket, err
+}
+
+// containerClientAndSharedKey is like containerClientAndName but also
+// returns the shared-key credential (if any) in effect for the request,
+// needed to sign a SAS by hand in signedURL's shared-key fallback path.
+func (c *client) containerClientAndSharedKey(
+	ctx context.Context,
+) (*container.Client, string, *azblob.SharedKeyCredential, error) {
+	settings := storage.SettingsFromContext(ctx)
+	if settings == nil {
+		return c.DefaultClient, c.container, c.credentials, nil
+	}
+	o, err := optionsFromSettings(settings)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	cc, sharedKey, err := newContainerClient(settings.Bucket, o)
+	return cc, settings.Bucket, sharedKey, err
+}
+
+func (c *client) blobClient(ctx context.Context, path string) (*blob.Client, error) {
+	cc, err := c.containerClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return cc.NewBlobClient(c.withRootDirectory(ctx, path)), nil
+}
+
+// withRootDirectory prepends the effective root directory (the per-request
+// override from ctx, or the client's own, in that order) to path.
+func (c *client) withRootDirectory(ctx context.Context, path string) string {
+	prefix := c.rootDirectory
+	if settings := storage.SettingsFromContext(ctx); settings != nil && settings.RootDirectory != "" {
+		prefix = settings.RootDirectory
+	}
+	if prefix == "" {
+		return path
+	}
+	return strings.TrimRight(prefix, "/") + "/" + strings.TrimLeft(path, "/")
+}
+
+func wrapNotFound(err error) error {
+	if err == nil {
+		return nil
+	}
+	if bloberror.HasCode(err, bloberror.BlobNotFound, bloberror.ContainerNotFound) {
+		return errors.Join(storage.ErrObjectNotFound, err)
+	}
+	return err
+}
+
+func (c *client) GetObject(ctx context.Context, path string) (io.ReadCloser, error) {
+	bc, err := c.blobClient(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	rsp, err := bc.DownloadStream(ctx, nil)
+	if err != nil {
+		return nil, wrapNotFound(err)
+	}
+	return rsp.Body, nil
+}
+
+func (c *client) PutObject(ctx context.Context, path string, src io.Reader) error {
+	cc, err := c.containerClient(ctx)
+	if err != nil {
+		return err
+	}
+	bc := cc.NewBlockBlobClient(c.withRootDirectory(ctx, path))
+	bufferSize := c.bufferSize
+	if bufferSize == 0 {
+		bufferSize = BufferSizeDefault
+	}
+	opts := &blockblob.UploadStreamOptions{
+		BlockSize: int64(bufferSize),
+	}
+	if c.contentType != nil {
+		opts.HTTPHeaders = &blob.HTTPHeaders{BlobContentType: c.contentType}
+	}
+	_, err = bc.UploadStream(ctx, src, opts)
+	return wrapNotFound(err)
+}
+
+func (c *client) DeleteObject(ctx context.Context, path string) error {
+	bc, err := c.blobClient(ctx, path)
+	if err != nil {
+		return err
+	}
+	_, err = bc.Delete(ctx, nil)
+	return wrapNotFound(err)
+}
+
+func (c *client) StatObject(ctx context.Context, path string) (*storage.ObjectInfo, error) {
+	bc, err := c.blobClient(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	rsp, err := bc.GetProperties(ctx, nil)
+	if err != nil {
+		return nil, wrapNotFound(err)
+	}
+	return &storage.ObjectInfo{
+		Path:         path,
+		Size:         rsp.ContentLength,
+		LastModified: rsp.LastModified,
+	}, nil
+}
+
+func (c *client) signedURL(
+	ctx context.Context, path, filename string,
+	perms sas.BlobPermissions, duration time.Duration,
+) (*model.Link, error) {
+	if c.credential != nil && c.serviceClient != nil {
+		return c.delegationSignedURL(ctx, path, filename, perms, duration)
+	}
+
+	cc, containerName, sharedKey, err := c.containerClientAndSharedKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if sharedKey == nil {
+		return nil, ErrNoCredentials
+	}
+
+	start := time.Now().Add(-delegationKeyClockSkew).UTC()
+	expiry := time.Now().Add(duration).UTC()
+	sv := sas.BlobSignatureValues{
+		Protocol:      sas.ProtocolHTTPS,
+		StartTime:     start,
+		ExpiryTime:    expiry,
+		Permissions:   perms.String(),
+		ContainerName: containerName,
+		BlobName:      c.withRootDirectory(ctx, path),
+	}
+	if filename != "" {
+		sv.ContentDisposition = "attachment; filename=" + filename
+	}
+	qp, err := sv.SignWithSharedKey(sharedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	url := cc.NewBlobClient(c.withRootDirectory(ctx, path)).URL() + "?" + qp.Encode()
+	return &model.Link{Uri: url, ExpireTime: expiry}, nil
+}
+
+func (c *client) GetRequest(
+	ctx context.Context, path, filename string, duration time.Duration, public bool,
+) (*model.Link, error) {
+	bc, err := c.blobClient(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := bc.GetProperties(ctx, nil); err != nil {
+		return nil, wrapNotFound(err)
+	}
+	link, err := c.signedURL(ctx, path, filename, sas.BlobPermissions{Read: true}, duration)
+	if err != nil {
+		return nil, err
+	}
+	link.Method = `GET`
+	return link, nil
+}
+
+func (c *client) PutRequest(
+	ctx context.Context, path string, duration time.Duration, public bool,
+) (*model.Link, error) {
+	link, err := c.signedURL(ctx, path, "", sas.BlobPermissions{Create: true, Write: true}, duration)
+	if err != nil {
+		return nil, err
+	}
+	link.Method = `PUT`
+	link.Header = map[string]string{"x-ms-blob-type": "BlockBlob"}
+	if c.contentType != nil {
+		link.Header["Content-Type"] = *c.contentType
+	}
+	return link, nil
+}
+
+func (c *client) DeleteRequest(
+	ctx context.Context, path string, duration time.Duration, public bool,
+) (*model.Link, error) {
+	link, err := c.signedURL(ctx, path, "", sas.BlobPermissions{Delete: true}, duration)
+	if err != nil {
+		return nil, err
+	}
+	link.Method = `DELETE`
+	return link, nil
+}
+
+// healthCheckTimeout bounds the user-delegation-key probe in HealthCheck so
+// a hung Azure AD token endpoint cannot stall a k8s liveness/readiness
+// check indefinitely.
+const healthCheckTimeout = 5 * time.Second
+
+func (c *client) HealthCheck(ctx context.Context) error {
+	cc, err := c.containerClient(ctx)
+	if err != nil {
+		return classifyHealthCheckError(err)
+	}
+	if _, err = cc.GetProperties(ctx, nil); err != nil {
+		return classifyHealthCheckError(err)
+	}
+
+	if c.credential != nil && c.serviceClient != nil {
+		svc, err := c.serviceClientFor(ctx)
+		if err != nil {
+			return classifyHealthCheckError(err)
+		}
+		dctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+		defer cancel()
+		// Probe the delegation-key endpoint directly rather than through
+		// c.delegationKey: healthCheckTimeout is far shorter than any real
+		// signed-URL request's duration, and caching it (or rescheduling
+		// the shared refresh timer around it) would let a single liveness
+		// probe hijack the production SAS delegation cache's cadence,
+		// forcing a refetch every few seconds.
+		if _, _, err = fetchDelegationKey(dctx, svc, healthCheckTimeout); err != nil {
+			return classifyHealthCheckError(err)
+		}
+	}
+	return nil
+}
+
+// classifyHealthCheckError maps an Azure SDK error to one of the
+// storage.ErrHealthCheck* sentinels so callers can report actionable
+// liveness/readiness status instead of a generic failure.
+func classifyHealthCheckError(err error) error {
+	if err == nil {
+		return nil
+	}
+	switch {
+	case bloberror.HasCode(err,
+		bloberror.ContainerNotFound,
+		bloberror.ContainerBeingDeleted):
+		return errors.Join(storage.ErrHealthCheckContainerMissing, err)
+	case bloberror.HasCode(err,
+		bloberror.AuthenticationFailed,
+		bloberror.AuthorizationFailure,
+		bloberror.InsufficientAccountPermissions):
+		return errors.Join(storage.ErrHealthCheckAuthFailed, err)
+	case isNetworkError(err):
+		return errors.Join(storage.ErrHealthCheckNetwork, err)
+	default:
+		return err
+	}
+}
+
+func isNetworkError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,206 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package azblob
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/sas"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/service"
+
+	"github.com/mendersoftware/mender-server/services/deployments/model"
+	"github.com/mendersoftware/mender-server/services/deployments/storage"
+)
+
+// delegationKeyClockSkew is subtracted from "now" when requesting a user
+// delegation key so that clock drift between the client and the blob
+// service does not make a freshly minted SAS appear to start in the future.
+const delegationKeyClockSkew = 5 * time.Minute
+
+// delegationRefreshMargin is how long before a delegation key expires the
+// background timer fetches a replacement.
+const delegationRefreshMargin = time.Minute
+
+// delegationCache holds the most recently fetched user delegation key per
+// blob-service endpoint, refreshed on a background timer bounded by the
+// caller's requested SAS duration so that rotating account keys never
+// invalidates an outstanding signed URL minted from a still-valid
+// delegation key. It is keyed by service endpoint, not shared across the
+// whole client, because a user-delegation key is only valid for signing
+// against the account that issued it, and two requests against the same
+// *client can carry per-tenant model.StorageSettings pointing at different
+// Azure AD credentials/storage accounts.
+type delegationCache struct {
+	mu      sync.Mutex
+	entries map[string]*delegationCacheEntry
+}
+
+type delegationCacheEntry struct {
+	key    *service.UserDelegationCredential
+	expiry time.Time
+	cancel context.CancelFunc
+}
+
+// serviceClientFor resolves the service.Client used to fetch a user
+// delegation key for this request: the per-tenant client described by the
+// model.StorageSettings in ctx, when present, or the one configured at
+// construction time. A user-delegation key is only valid against the
+// account that issued it, so signing against the wrong service client here
+// would produce a SAS Azure rejects for any tenant other than whichever
+// one happened to be active when c was constructed.
+func (c *client) serviceClientFor(ctx context.Context) (*service.Client, error) {
+	settings := storage.SettingsFromContext(ctx)
+	if settings == nil {
+		return c.serviceClient, nil
+	}
+	o, err := optionsFromSettings(settings)
+	if err != nil {
+		return nil, err
+	}
+	if o.credential == nil || o.uri == nil {
+		return c.serviceClient, nil
+	}
+	return service.NewClient(*o.uri, o.credential, nil)
+}
+
+// delegationSignedURL mints a SAS using an Azure AD user-delegation key
+// instead of the account shared key, scoped to the minimum permission set
+// the caller requested.
+func (c *client) delegationSignedURL(
+	ctx context.Context, path, filename string,
+	perms sas.BlobPermissions, duration time.Duration,
+) (*model.Link, error) {
+	cc, containerName, err := c.containerClientAndName(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	svc, err := c.serviceClientFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := c.delegationKey(ctx, svc, duration)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now().Add(-delegationKeyClockSkew).UTC()
+	expiry := time.Now().Add(duration).UTC()
+	sv := sas.BlobSignatureValues{
+		Protocol:      sas.ProtocolHTTPS,
+		StartTime:     start,
+		ExpiryTime:    expiry,
+		Permissions:   perms.String(),
+		ContainerName: containerName,
+		BlobName:      c.withRootDirectory(ctx, path),
+	}
+	if filename != "" {
+		sv.ContentDisposition = "attachment; filename=" + filename
+	}
+	qp, err := sv.SignWithUserDelegation(key)
+	if err != nil {
+		return nil, err
+	}
+
+	url := cc.NewBlobClient(c.withRootDirectory(ctx, path)).URL() + "?" + qp.Encode()
+	return &model.Link{Uri: url, ExpireTime: expiry}, nil
+}
+
+// delegationKey returns a cached user delegation key valid for at least
+// duration for svc's endpoint, fetching (or refreshing) one from the blob
+// service when needed.
+func (c *client) delegationKey(
+	ctx context.Context, svc *service.Client, duration time.Duration,
+) (*service.UserDelegationCredential, error) {
+	endpoint := svc.URL()
+
+	c.delegation.mu.Lock()
+	if entry, ok := c.delegation.entries[endpoint]; ok &&
+		entry.key != nil && time.Now().Add(duration).Before(entry.expiry) {
+		c.delegation.mu.Unlock()
+		return entry.key, nil
+	}
+	c.delegation.mu.Unlock()
+
+	key, expiry, err := fetchDelegationKey(ctx, svc, duration)
+	if err != nil {
+		return nil, err
+	}
+
+	c.delegation.mu.Lock()
+	defer c.delegation.mu.Unlock()
+	if c.delegation.entries == nil {
+		c.delegation.entries = make(map[string]*delegationCacheEntry)
+	}
+	entry := c.delegation.entries[endpoint]
+	if entry == nil {
+		entry = &delegationCacheEntry{}
+		c.delegation.entries[endpoint] = entry
+	} else if entry.cancel != nil {
+		entry.cancel()
+	}
+	entry.key = key
+	entry.expiry = expiry
+	c.scheduleDelegationRefresh(svc, duration, entry)
+	return key, nil
+}
+
+// fetchDelegationKey requests a fresh user delegation key valid for at
+// least duration directly from svc, without consulting or updating the
+// shared delegation cache.
+func fetchDelegationKey(
+	ctx context.Context, svc *service.Client, duration time.Duration,
+) (*service.UserDelegationCredential, time.Time, error) {
+	start := time.Now().Add(-delegationKeyClockSkew).UTC()
+	expiry := time.Now().Add(duration).UTC()
+	info := service.KeyInfo{
+		Start:  toPtr(start.Format(sas.TimeFormat)),
+		Expiry: toPtr(expiry.Format(sas.TimeFormat)),
+	}
+	key, err := svc.GetUserDelegationCredential(ctx, info, nil)
+	return key, expiry, err
+}
+
+// scheduleDelegationRefresh arms a background timer that refetches svc's
+// delegation key shortly before it expires, bounded by the requested SAS
+// duration. The caller must hold c.delegation.mu.
+func (c *client) scheduleDelegationRefresh(
+	svc *service.Client, duration time.Duration, entry *delegationCacheEntry,
+) {
+	refreshIn := duration - delegationRefreshMargin
+	if refreshIn <= 0 {
+		refreshIn = duration / 2
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	entry.cancel = cancel
+	timer := time.AfterFunc(refreshIn, func() {
+		if ctx.Err() != nil {
+			return
+		}
+		_, _ = c.delegationKey(context.Background(), svc, duration)
+	})
+	go func() {
+		<-ctx.Done()
+		timer.Stop()
+	}()
+}
+
+func toPtr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,168 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package azblob
+
+import (
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+)
+
+const (
+	BufferSizeMin     = 1024 * 1024
+	BufferSizeDefault = 8 * 1024 * 1024
+	BufferSizeMax     = 100 * 1024 * 1024
+)
+
+// SharedKeyCredentials holds an Azure storage account shared-key credential
+// pair.
+type SharedKeyCredentials struct {
+	AccountName string
+	AccountKey  string
+}
+
+// azParams derives the container service URL and the azblob shared-key
+// credential from the account name/key pair.
+func (c SharedKeyCredentials) azParams(container string) (string, *azblob.SharedKeyCredential, error) {
+	cred, err := azblob.NewSharedKeyCredential(c.AccountName, c.AccountKey)
+	if err != nil {
+		return "", nil, err
+	}
+	url := "https://" + c.AccountName + ".blob.core.windows.net/" + container
+	return url, cred, nil
+}
+
+// Options holds the parameters for constructing an azblob object storage
+// client with New.
+type Options struct {
+	err error
+
+	contentType *string
+	bufferSize  int
+
+	uri *string
+
+	rootDirectory *string
+
+	connectionString *string
+	sharedKey        *SharedKeyCredentials
+	credential       azcore.TokenCredential
+}
+
+// NewOptions returns an Options initialized with the package defaults.
+func NewOptions() *Options {
+	return &Options{
+		bufferSize: BufferSizeDefault,
+	}
+}
+
+// SetContentType sets the Content-Type that PutObject/PutRequest associate
+// with uploaded blobs.
+func (opts *Options) SetContentType(contentType string) *Options {
+	opts.contentType = &contentType
+	return opts
+}
+
+// SetBufferSize sets the buffer size, in bytes, used for staging blocks when
+// streaming uploads. Values outside [BufferSizeMin, BufferSizeMax] are
+// rejected by New.
+func (opts *Options) SetBufferSize(bufferSize int) *Options {
+	opts.bufferSize = bufferSize
+	return opts
+}
+
+// SetURI overrides the blob service URL, required when authenticating with a
+// token credential rather than a connection string (which embeds the
+// endpoint).
+func (opts *Options) SetURI(uri string) *Options {
+	opts.uri = &uri
+	return opts
+}
+
+// SetRootDirectory sets a path prefix prepended to every object key, so a
+// single container can be shared across multiple tenants or environments
+// without collisions.
+func (opts *Options) SetRootDirectory(rootDirectory string) *Options {
+	opts.rootDirectory = &rootDirectory
+	return opts
+}
+
+// SetConnectionString configures the client to authenticate using an Azure
+// storage account connection string.
+func (opts *Options) SetConnectionString(connectionString string) *Options {
+	opts.connectionString = &connectionString
+	return opts
+}
+
+// SetSharedKey configures the client to authenticate using an Azure storage
+// account shared key.
+func (opts *Options) SetSharedKey(creds SharedKeyCredentials) *Options {
+	opts.sharedKey = &creds
+	return opts
+}
+
+// SetClientSecretCredential configures the client to authenticate as an
+// Azure AD application using a client secret.
+func (opts *Options) SetClientSecretCredential(tenantID, clientID, secret string) *Options {
+	if opts.err != nil {
+		return opts
+	}
+	cred, err := azidentity.NewClientSecretCredential(tenantID, clientID, secret, nil)
+	if err != nil {
+		opts.err = err
+		return opts
+	}
+	opts.credential = cred
+	return opts
+}
+
+// SetManagedIdentity configures the client to authenticate using the
+// system-assigned managed identity of the host, or the user-assigned
+// identity identified by clientID when non-empty.
+func (opts *Options) SetManagedIdentity(clientID string) *Options {
+	if opts.err != nil {
+		return opts
+	}
+	var id azidentity.ManagedIDKind
+	if clientID != "" {
+		id = azidentity.ClientID(clientID)
+	}
+	cred, err := azidentity.NewManagedIdentityCredential(&azidentity.ManagedIdentityCredentialOptions{
+		ID: id,
+	})
+	if err != nil {
+		opts.err = err
+		return opts
+	}
+	opts.credential = cred
+	return opts
+}
+
+// SetDefaultCredential configures the client to authenticate using
+// DefaultAzureCredential, which tries environment variables, workload
+// identity federation, managed identity, and the Azure CLI, in that order.
+// This is the recommended mode for pod-level identity in Kubernetes.
+func (opts *Options) SetDefaultCredential() *Options {
+	if opts.err != nil {
+		return opts
+	}
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		opts.err = err
+		return opts
+	}
+	opts.credential = cred
+	return opts
+}
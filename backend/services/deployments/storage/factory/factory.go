@@ -0,0 +1,45 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+// Package factory selects and constructs the configured storage.ObjectStorage
+// backend. It lives apart from package storage to avoid an import cycle, as
+// every backend imports storage for the interface and shared types it
+// implements against.
+package factory
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mendersoftware/mender-server/services/deployments/model"
+	"github.com/mendersoftware/mender-server/services/deployments/storage"
+	"github.com/mendersoftware/mender-server/services/deployments/storage/azblob"
+	"github.com/mendersoftware/mender-server/services/deployments/storage/gcs"
+)
+
+// New constructs the storage.ObjectStorage backend selected by
+// settings.Type. settings is carried into the backend constructors via ctx
+// (see storage.SettingsWithContext) so that credentials, RootDirectory and
+// backend-specific fields (e.g. settings.Azure) are honored, not just Bucket.
+func New(ctx context.Context, settings *model.StorageSettings) (storage.ObjectStorage, error) {
+	ctx = storage.SettingsWithContext(ctx, settings)
+	switch settings.Type {
+	case model.StorageTypeAzure:
+		return azblob.New(ctx, settings.Bucket)
+	case model.StorageTypeGCS:
+		return gcs.New(ctx, settings.Bucket)
+	default:
+		return nil, fmt.Errorf("factory: unsupported storage type %q", settings.Type)
+	}
+}
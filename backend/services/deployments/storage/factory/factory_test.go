@@ -0,0 +1,53 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package factory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/mender-server/services/deployments/model"
+)
+
+func TestNewUnsupportedType(t *testing.T) {
+	_, err := New(context.Background(), &model.StorageSettings{
+		Type:   model.StorageType("bogus"),
+		Bucket: "test-container",
+	})
+	assert.ErrorContains(t, err, "unsupported storage type")
+}
+
+// TestNewPassesSettingsToBackend builds a client purely from a connection
+// string, which the azblob SDK can parse and construct offline without a
+// network round trip. It exists to pin down that New forwards the full
+// settings (not just Bucket) into the backend constructor: before New
+// wrapped ctx with storage.SettingsWithContext, this failed with
+// azblob.ErrNoCredentials even though a valid ConnectionString was set.
+func TestNewPassesSettingsToBackend(t *testing.T) {
+	connStr := "DefaultEndpointsProtocol=https;" +
+		"AccountName=testaccount;" +
+		"AccountKey=a2V5MTIzNDU2Nzg=;" +
+		"EndpointSuffix=core.windows.net"
+
+	client, err := New(context.Background(), &model.StorageSettings{
+		Type:             model.StorageTypeAzure,
+		Bucket:           "test-container",
+		ConnectionString: &connStr,
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, client)
+}
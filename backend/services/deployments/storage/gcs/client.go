@@ -0,0 +1,200 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+// Package gcs implements storage.ObjectStorage on top of Google Cloud
+// Storage.
+package gcs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	gcstorage "cloud.google.com/go/storage"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"google.golang.org/api/option"
+
+	"github.com/mendersoftware/mender-server/services/deployments/model"
+	"github.com/mendersoftware/mender-server/services/deployments/storage"
+)
+
+var ErrNoBucket = errors.New("gcs: bucket name is required")
+
+// client implements storage.ObjectStorage on top of Google Cloud Storage.
+type client struct {
+	gcs    *gcstorage.Client
+	bucket string
+
+	contentType *string
+	bufferSize  int
+}
+
+// New initializes a gcs storage.ObjectStorage client for bucket. If opts is
+// empty, the client is initialized from the model.StorageSettings found in
+// ctx (see storage.SettingsWithContext); bucket still names the bucket, and
+// settings.Bucket is used only when bucket is empty.
+func New(ctx context.Context, bucket string, opts ...*Options) (*client, error) {
+	var o *Options
+	if len(opts) > 0 && opts[0] != nil {
+		o = opts[0]
+	} else {
+		settings := storage.SettingsFromContext(ctx)
+		if settings == nil {
+			return nil, ErrNoBucket
+		}
+		if err := validation.ValidateStruct(settings,
+			validation.Field(&settings.Bucket, validation.Required),
+		); err != nil {
+			return nil, err
+		}
+		if bucket == "" {
+			bucket = settings.Bucket
+		}
+		o = NewOptions()
+		if settings.CredentialsJSON != nil {
+			o.SetCredentialsJSON([]byte(*settings.CredentialsJSON))
+		}
+	}
+	if bucket == "" {
+		return nil, ErrNoBucket
+	}
+
+	var clientOpts []option.ClientOption
+	if o.credentialsJSON != nil {
+		clientOpts = append(clientOpts, option.WithCredentialsJSON(o.credentialsJSON))
+	}
+	gcsClient, err := gcstorage.NewClient(ctx, clientOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	bufferSize := o.bufferSize
+	if bufferSize == 0 {
+		bufferSize = BufferSizeDefault
+	}
+	return &client{
+		gcs:    gcsClient,
+		bucket: bucket,
+
+		contentType: o.contentType,
+		bufferSize:  bufferSize,
+	}, nil
+}
+
+func wrapNotFound(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, gcstorage.ErrObjectNotExist) || errors.Is(err, gcstorage.ErrBucketNotExist) {
+		return errors.Join(storage.ErrObjectNotFound, err)
+	}
+	return err
+}
+
+func (c *client) object(path string) *gcstorage.ObjectHandle {
+	return c.gcs.Bucket(c.bucket).Object(path)
+}
+
+func (c *client) GetObject(ctx context.Context, path string) (io.ReadCloser, error) {
+	r, err := c.object(path).NewReader(ctx)
+	if err != nil {
+		return nil, wrapNotFound(err)
+	}
+	return r, nil
+}
+
+func (c *client) PutObject(ctx context.Context, path string, src io.Reader) error {
+	w := c.object(path).NewWriter(ctx)
+	w.ChunkSize = c.bufferSize
+	if c.contentType != nil {
+		w.ContentType = *c.contentType
+	}
+	if _, err := io.Copy(w, src); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (c *client) DeleteObject(ctx context.Context, path string) error {
+	err := c.object(path).Delete(ctx)
+	return wrapNotFound(err)
+}
+
+func (c *client) StatObject(ctx context.Context, path string) (*storage.ObjectInfo, error) {
+	attrs, err := c.object(path).Attrs(ctx)
+	if err != nil {
+		return nil, wrapNotFound(err)
+	}
+	size := attrs.Size
+	return &storage.ObjectInfo{
+		Path:         path,
+		Size:         &size,
+		LastModified: &attrs.Updated,
+	}, nil
+}
+
+func (c *client) signedURL(
+	path, filename string, method string, duration time.Duration,
+) (*model.Link, error) {
+	opts := &gcstorage.SignedURLOptions{
+		Scheme:  gcstorage.SigningSchemeV4,
+		Method:  method,
+		Expires: time.Now().Add(duration),
+	}
+	if filename != "" {
+		opts.QueryParameters = map[string][]string{
+			"response-content-disposition": {"attachment; filename=" + filename},
+		}
+	}
+	url, err := c.gcs.Bucket(c.bucket).SignedURL(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &model.Link{Uri: url, Method: method, ExpireTime: opts.Expires}, nil
+}
+
+func (c *client) GetRequest(
+	ctx context.Context, path string, filename string, duration time.Duration, public bool,
+) (*model.Link, error) {
+	if _, err := c.object(path).Attrs(ctx); err != nil {
+		return nil, wrapNotFound(err)
+	}
+	return c.signedURL(path, filename, "GET", duration)
+}
+
+func (c *client) PutRequest(
+	ctx context.Context, path string, duration time.Duration, public bool,
+) (*model.Link, error) {
+	link, err := c.signedURL(path, "", "PUT", duration)
+	if err != nil {
+		return nil, err
+	}
+	if c.contentType != nil {
+		link.Header = map[string]string{"Content-Type": *c.contentType}
+	}
+	return link, nil
+}
+
+func (c *client) DeleteRequest(
+	ctx context.Context, path string, duration time.Duration, public bool,
+) (*model.Link, error) {
+	return c.signedURL(path, "", "DELETE", duration)
+}
+
+func (c *client) HealthCheck(ctx context.Context) error {
+	_, err := c.gcs.Bucket(c.bucket).Attrs(ctx)
+	return err
+}
@@ -0,0 +1,178 @@
+// Copyright 2026 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package gcs
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	gcstorage "cloud.google.com/go/storage"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/mender-server/services/deployments/model"
+	"github.com/mendersoftware/mender-server/services/deployments/storage"
+)
+
+// setFakeEmulator points the gcs client at a bogus STORAGE_EMULATOR_HOST so
+// New can construct a real *gcstorage.Client without falling back to
+// Application Default Credentials discovery, which isn't available in a
+// unit test environment.
+func setFakeEmulator(t *testing.T) {
+	t.Helper()
+	t.Setenv("STORAGE_EMULATOR_HOST", "127.0.0.1:0")
+}
+
+func TestNew(t *testing.T) {
+	setFakeEmulator(t)
+
+	testCases := []struct {
+		Name string
+
+		CTX    context.Context
+		Bucket string
+		Opts   *Options
+
+		Error error
+	}{{
+		Name: "ok/explicit options and bucket",
+
+		CTX:    context.Background(),
+		Bucket: "test-bucket",
+		Opts:   NewOptions(),
+	}, {
+		Name: "ok/bucket from context settings",
+
+		CTX: storage.SettingsWithContext(context.Background(), &model.StorageSettings{
+			Bucket: "test-bucket",
+		}),
+	}, {
+		Name: "ok/explicit bucket overrides context settings",
+
+		CTX: storage.SettingsWithContext(context.Background(), &model.StorageSettings{
+			Bucket: "other-bucket",
+		}),
+		Bucket: "test-bucket",
+	}, {
+		Name: "error/no bucket and no context settings",
+
+		CTX:   context.Background(),
+		Error: ErrNoBucket,
+	}, {
+		Name: "error/context settings missing bucket",
+
+		CTX:   storage.SettingsWithContext(context.Background(), &model.StorageSettings{}),
+		Error: validation.Errors{},
+	}}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			var opts []*Options
+			if tc.Opts != nil {
+				opts = []*Options{tc.Opts}
+			}
+			c, err := New(tc.CTX, tc.Bucket, opts...)
+			if tc.Error != nil {
+				assert.Error(t, err)
+				if errors.Is(tc.Error, ErrNoBucket) {
+					assert.ErrorIs(t, err, tc.Error)
+				} else {
+					var verr validation.Errors
+					assert.ErrorAs(t, err, &verr)
+				}
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, "test-bucket", c.bucket)
+			}
+		})
+	}
+}
+
+func TestNewUsesOptionsBufferSize(t *testing.T) {
+	setFakeEmulator(t)
+
+	c, err := New(context.Background(), "test-bucket", NewOptions().SetBufferSize(BufferSizeMin))
+	if assert.NoError(t, err) {
+		assert.Equal(t, BufferSizeMin, c.bufferSize)
+	}
+}
+
+func TestWrapNotFound(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		Name string
+
+		Err error
+
+		ExpectWrapped bool
+	}{{
+		Name: "nil",
+	}, {
+		Name: "object not found",
+
+		Err:           gcstorage.ErrObjectNotExist,
+		ExpectWrapped: true,
+	}, {
+		Name: "bucket not found",
+
+		Err:           gcstorage.ErrBucketNotExist,
+		ExpectWrapped: true,
+	}, {
+		Name: "unrelated error passes through unchanged",
+
+		Err: errors.New("boom"),
+	}}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+
+			err := wrapNotFound(tc.Err)
+			if tc.Err == nil {
+				assert.NoError(t, err)
+				return
+			}
+			if tc.ExpectWrapped {
+				assert.ErrorIs(t, err, storage.ErrObjectNotFound)
+			}
+			assert.ErrorIs(t, err, tc.Err)
+		})
+	}
+}
+
+func TestOptions(t *testing.T) {
+	t.Parallel()
+
+	o := NewOptions()
+	assert.Equal(t, BufferSizeDefault, o.bufferSize)
+	assert.Nil(t, o.contentType)
+	assert.Nil(t, o.credentialsJSON)
+
+	o.SetContentType("vnd/testing")
+	if assert.NotNil(t, o.contentType) {
+		assert.Equal(t, "vnd/testing", *o.contentType)
+	}
+
+	o.SetBufferSize(BufferSizeMin)
+	assert.Equal(t, BufferSizeMin, o.bufferSize)
+
+	o.SetCredentialsJSON([]byte(`{"type":"service_account"}`))
+	assert.Equal(t, []byte(`{"type":"service_account"}`), o.credentialsJSON)
+}
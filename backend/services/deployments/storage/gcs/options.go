@@ -0,0 +1,60 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package gcs
+
+const (
+	BufferSizeMin     = 1024 * 1024
+	BufferSizeDefault = 8 * 1024 * 1024
+	BufferSizeMax     = 100 * 1024 * 1024
+)
+
+// Options holds the parameters for constructing a gcs object storage client
+// with New.
+type Options struct {
+	contentType *string
+	bufferSize  int
+
+	// credentialsJSON holds a GCP service-account JSON key. When nil, the
+	// client authenticates using Application Default Credentials.
+	credentialsJSON []byte
+}
+
+// NewOptions returns an Options initialized with the package defaults.
+func NewOptions() *Options {
+	return &Options{
+		bufferSize: BufferSizeDefault,
+	}
+}
+
+// SetContentType sets the Content-Type that PutObject/PutRequest associate
+// with uploaded objects.
+func (opts *Options) SetContentType(contentType string) *Options {
+	opts.contentType = &contentType
+	return opts
+}
+
+// SetBufferSize sets the buffer size, in bytes, used when streaming uploads.
+func (opts *Options) SetBufferSize(bufferSize int) *Options {
+	opts.bufferSize = bufferSize
+	return opts
+}
+
+// SetCredentialsJSON configures the client to authenticate using a GCP
+// service-account JSON key, required to sign URLs without calling the IAM
+// credentials API.
+func (opts *Options) SetCredentialsJSON(credentialsJSON []byte) *Options {
+	opts.credentialsJSON = credentialsJSON
+	return opts
+}
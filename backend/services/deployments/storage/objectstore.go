@@ -25,6 +25,14 @@ import (
 
 var (
 	ErrObjectNotFound = errors.New("object not found")
+
+	// The following sentinel errors let HealthCheck implementations
+	// classify a failure for liveness/readiness probes, so that a
+	// misconfigured per-tenant model.StorageSettings degrades gracefully
+	// instead of surfacing as a generic 500 on the first upload.
+	ErrHealthCheckAuthFailed       = errors.New("storage: authentication failed")
+	ErrHealthCheckContainerMissing = errors.New("storage: bucket/container not found")
+	ErrHealthCheckNetwork          = errors.New("storage: network error reaching storage backend")
 )
 
 // ObjectStorage allows to store and manage large files
@@ -54,6 +62,49 @@ type ObjectInfo struct {
 	LastModified *time.Time
 }
 
+// MultipartStorage is an optional capability implemented by backends that
+// can stream large objects as a series of independently-signed parts
+// instead of a single buffered PutObject, so that device clients / mender-cli
+// can resume and parallelize multi-GB artifact uploads. Backends that do not
+// implement it (e.g. smaller object stores without a native multipart API)
+// fall back to the buffered PutObject path.
+type MultipartStorage interface {
+	ObjectStorage
+
+	// MultipartUpload starts a new multipart upload for path and returns
+	// a session ID along with one signed PUT link per part. parts must
+	// be a positive, known part count, since every backend's
+	// part/block-level signed URL needs to be pre-addressed.
+	MultipartUpload(ctx context.Context, path string, parts int,
+		duration time.Duration) (*MultipartUploadSession, error)
+
+	// CompleteMultipartUpload assembles the parts previously uploaded
+	// via the signed links from MultipartUpload into the final object at
+	// path. parts must be given in ascending PartNumber order.
+	CompleteMultipartUpload(ctx context.Context, path string, uploadID string,
+		parts []CompletedPart) error
+}
+
+// MultipartUploadSession is returned by MultipartStorage.MultipartUpload.
+type MultipartUploadSession struct {
+	UploadID string
+
+	// PartURLs holds one signed PUT link per part, in ascending
+	// PartNumber order starting at 1.
+	PartURLs []model.Link
+}
+
+// CompletedPart identifies one part of a multipart upload for
+// MultipartStorage.CompleteMultipartUpload.
+type CompletedPart struct {
+	PartNumber int
+
+	// ETag is the value returned by the backend in response to the
+	// part's signed PUT request (S3 requires it; azblob ignores it since
+	// staged blocks are addressed by block ID alone).
+	ETag string
+}
+
 type ObjectReader interface {
 	io.Reader
 
@@ -0,0 +1,102 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const authzTestPolicy = `package mender.authz
+
+default allow = false
+
+allow {
+	input.caller.roles[_] == "admin"
+}
+
+allow {
+	input.workflow.name == "decommission_device"
+	input.caller.tenant_id != ""
+}
+`
+
+func writeAuthzBundle(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "authz.rego"), []byte(authzTestPolicy), 0666)
+	assert.NoError(t, err)
+	return dir
+}
+
+func TestOPAAuthorizerAllowsAdmin(t *testing.T) {
+	ctx := context.Background()
+	dir := writeAuthzBundle(t)
+
+	authorizer, err := NewOPAAuthorizer(ctx, dir, "data.mender.authz.allow")
+	assert.NoError(t, err)
+
+	err = authorizer.Authorize(ctx, AuthorizationInput{
+		Workflow: WorkflowAuthInput{Name: "provision_device"},
+		Caller:   CallerAuthInput{TenantID: "tenant1", Roles: []string{"admin"}},
+	})
+	assert.NoError(t, err)
+}
+
+func TestOPAAuthorizerDeniesByDefault(t *testing.T) {
+	ctx := context.Background()
+	dir := writeAuthzBundle(t)
+
+	authorizer, err := NewOPAAuthorizer(ctx, dir, "data.mender.authz.allow")
+	assert.NoError(t, err)
+
+	err = authorizer.Authorize(ctx, AuthorizationInput{
+		Workflow: WorkflowAuthInput{Name: "provision_device"},
+		Caller:   CallerAuthInput{TenantID: "tenant1", Roles: []string{"viewer"}},
+	})
+	assert.Error(t, err)
+	var authzErr *AuthorizationError
+	assert.ErrorAs(t, err, &authzErr)
+}
+
+func TestOPAAuthorizerRefreshPicksUpBundleChanges(t *testing.T) {
+	ctx := context.Background()
+	dir := writeAuthzBundle(t)
+
+	authorizer, err := NewOPAAuthorizer(ctx, dir, "data.mender.authz.allow")
+	assert.NoError(t, err)
+
+	input := AuthorizationInput{
+		Workflow: WorkflowAuthInput{Name: "some_workflow"},
+		Caller:   CallerAuthInput{TenantID: "tenant1", Roles: []string{"viewer"}},
+	}
+	assert.Error(t, authorizer.Authorize(ctx, input))
+
+	err = os.WriteFile(filepath.Join(dir, "authz.rego"), []byte(`package mender.authz
+
+allow = true
+`), 0666)
+	assert.NoError(t, err)
+
+	reloaded, err := authorizer.Refresh(ctx)
+	assert.NoError(t, err)
+	assert.True(t, reloaded)
+
+	assert.NoError(t, authorizer.Authorize(ctx, input))
+}
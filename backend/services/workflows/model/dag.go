@@ -0,0 +1,364 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// ErrDependencyCycle is returned by RunDAG when a workflow's tasks cannot
+// be ordered because two or more of them depend on each other.
+var ErrDependencyCycle = errors.New("model: workflow tasks form a dependency cycle")
+
+// ErrUnknownTaskReference is returned by RunDAG when a task's Requires,
+// When or ForEach.Items expression references a "tasks.<name>" that isn't
+// an actual task in the workflow - such a reference can never be
+// satisfied, so RunDAG rejects it up front instead of blocking forever
+// waiting for it.
+var ErrUnknownTaskReference = errors.New("model: task references an unknown task name")
+
+// ForEach fans a task out across a collection: Items is a "${...}"
+// template expression that must resolve to a slice, the task is run once
+// per element with As bound to that element in scope, and the aggregated
+// per-element results are exposed to later tasks as
+// "${tasks.<name>.results}". MaxParallel bounds how many elements run at
+// once; zero means the workflow's MaxConcurrency applies instead.
+type ForEach struct {
+	Items       string `json:"items"`
+	As          string `json:"as"`
+	MaxParallel int    `json:"maxParallel,omitempty"`
+}
+
+// TaskResult is the outcome of running a single Task under RunDAG.
+type TaskResult struct {
+	Skipped bool
+	Output  interface{}
+	Results []interface{}
+	Err     error
+}
+
+// Executor runs a single Task (or, under ForEach, a single element of it)
+// and returns its output. The worker package is expected to provide a
+// concrete Executor that dispatches HTTPTask/CLITask/etc; RunDAG itself
+// only handles ordering, conditions and fan-out.
+type Executor func(ctx context.Context, task Task, scope map[string]interface{}) (interface{}, error)
+
+var taskRefPattern = regexp.MustCompile(`tasks\.([A-Za-z0-9_-]+)`)
+
+// dependencies returns the names of tasks that task's Requires, When and
+// ForEach.Items expressions reference via "tasks.<name>", which is enough
+// to build a DAG edge without requiring those fields to list task names
+// directly.
+func dependencies(task Task) []string {
+	var exprs []string
+	exprs = append(exprs, task.Requires...)
+	if task.When != "" {
+		exprs = append(exprs, task.When)
+	}
+	if task.ForEach != nil {
+		exprs = append(exprs, task.ForEach.Items)
+	}
+
+	seen := make(map[string]bool)
+	var deps []string
+	for _, expr := range exprs {
+		for _, match := range taskRefPattern.FindAllStringSubmatch(expr, -1) {
+			name := match[1]
+			if !seen[name] {
+				seen[name] = true
+				deps = append(deps, name)
+			}
+		}
+	}
+	return deps
+}
+
+// BuildDependencies returns, for every task in workflow, the names of the
+// other tasks it must wait for.
+func BuildDependencies(workflow *Workflow) map[string][]string {
+	deps := make(map[string][]string, len(workflow.Tasks))
+	for _, task := range workflow.Tasks {
+		deps[task.Name] = dependencies(task)
+	}
+	return deps
+}
+
+// validateDependencies rejects a dependency map that references a task
+// name not present in workflow, which would otherwise leave RunDAG
+// waiting forever for a result that can never arrive.
+func validateDependencies(workflow *Workflow, deps map[string][]string) error {
+	known := make(map[string]bool, len(workflow.Tasks))
+	for _, task := range workflow.Tasks {
+		known[task.Name] = true
+	}
+	for name, refs := range deps {
+		for _, ref := range refs {
+			if !known[ref] {
+				return fmt.Errorf(
+					"%w: task %q references %q", ErrUnknownTaskReference, name, ref,
+				)
+			}
+		}
+	}
+	return nil
+}
+
+// RunDAG schedules workflow's tasks concurrently, honoring dependencies
+// derived by BuildDependencies, Task.When conditions and Task.ForEach
+// fan-out, up to workflow.MaxConcurrency tasks in flight at once.
+func RunDAG(
+	ctx context.Context, workflow *Workflow, inputs map[string]interface{}, exec Executor,
+) (map[string]*TaskResult, error) {
+	deps := BuildDependencies(workflow)
+	if err := validateDependencies(workflow, deps); err != nil {
+		return nil, err
+	}
+	if err := checkAcyclic(deps); err != nil {
+		return nil, err
+	}
+
+	tasksByName := make(map[string]Task, len(workflow.Tasks))
+	for _, task := range workflow.Tasks {
+		tasksByName[task.Name] = task
+	}
+
+	maxConcurrency := workflow.MaxConcurrency
+	if maxConcurrency <= 0 {
+		maxConcurrency = len(workflow.Tasks)
+	}
+	if maxConcurrency <= 0 {
+		maxConcurrency = 1
+	}
+	sem := make(chan struct{}, maxConcurrency)
+
+	var mu sync.Mutex
+	results := make(map[string]*TaskResult, len(workflow.Tasks))
+	scope := map[string]interface{}{
+		"workflow": map[string]interface{}{"input": inputs},
+		"tasks":    map[string]interface{}{},
+	}
+
+	done := make(chan string, len(workflow.Tasks))
+	pending := make(map[string]bool, len(workflow.Tasks))
+	for name := range deps {
+		pending[name] = true
+	}
+
+	var wg sync.WaitGroup
+	var firstErr error
+
+	ready := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		var names []string
+		for name := range pending {
+			satisfied := true
+			for _, dep := range deps[name] {
+				if _, ok := results[dep]; !ok {
+					satisfied = false
+					break
+				}
+			}
+			if satisfied {
+				names = append(names, name)
+				delete(pending, name)
+			}
+		}
+		return names
+	}
+
+	runOne := func(name string) {
+		defer wg.Done()
+		defer func() { done <- name }()
+		task := tasksByName[name]
+
+		mu.Lock()
+		taskScope := copyScope(scope)
+		mu.Unlock()
+
+		result := &TaskResult{}
+		if task.When != "" {
+			v, err := EvalExpr(task.When, taskScope)
+			if err != nil {
+				result.Err = fmt.Errorf("model: evaluating when for task %q: %w", name, err)
+			} else if !Truthy(v) {
+				result.Skipped = true
+			}
+		}
+		if result.Err == nil && !result.Skipped {
+			for _, req := range task.Requires {
+				v, err := EvalTemplate(req, taskScope)
+				if err != nil {
+					result.Err = fmt.Errorf(
+						"model: evaluating requires for task %q: %w", name, err,
+					)
+					break
+				}
+				if !Truthy(v) {
+					result.Skipped = true
+					break
+				}
+			}
+		}
+
+		if result.Err == nil && !result.Skipped {
+			if task.ForEach != nil {
+				result.Results, result.Err = runForEach(ctx, task, taskScope, exec, sem)
+			} else {
+				sem <- struct{}{}
+				result.Output, result.Err = exec(ctx, task, taskScope)
+				<-sem
+			}
+		}
+
+		mu.Lock()
+		results[name] = result
+		entry := map[string]interface{}{}
+		if result.Output != nil {
+			entry["output"] = result.Output
+		}
+		if result.Results != nil {
+			entry["results"] = result.Results
+		}
+		scope["tasks"].(map[string]interface{})[name] = entry
+		if result.Err != nil && firstErr == nil {
+			firstErr = result.Err
+		}
+		mu.Unlock()
+	}
+
+	// Re-check ready() after every single completion, not just after a
+	// whole wave drains: a task blocked only on the one sibling that just
+	// finished must be able to start immediately, without waiting for
+	// unrelated, longer-running siblings launched in the same wave.
+	remaining := len(deps)
+	for remaining > 0 {
+		for _, name := range ready() {
+			wg.Add(1)
+			go runOne(name)
+		}
+		<-done
+		remaining--
+	}
+	wg.Wait()
+
+	return results, firstErr
+}
+
+func runForEach(
+	ctx context.Context, task Task, scope map[string]interface{}, exec Executor, sem chan struct{},
+) ([]interface{}, error) {
+	itemsValue, err := EvalTemplate(task.ForEach.Items, scope)
+	if err != nil {
+		return nil, fmt.Errorf("model: evaluating foreach.items for task %q: %w", task.Name, err)
+	}
+	items, ok := itemsValue.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf(
+			"model: foreach.items for task %q did not resolve to a collection", task.Name,
+		)
+	}
+
+	maxParallel := task.ForEach.MaxParallel
+	if maxParallel <= 0 {
+		maxParallel = cap(sem)
+	}
+	localSem := make(chan struct{}, maxParallel)
+
+	results := make([]interface{}, len(items))
+	errs := make([]error, len(items))
+	var wg sync.WaitGroup
+	for i, item := range items {
+		wg.Add(1)
+		go func(i int, item interface{}) {
+			defer wg.Done()
+			localSem <- struct{}{}
+			defer func() { <-localSem }()
+
+			itemScope := copyScope(scope)
+			itemScope[task.ForEach.As] = item
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i], errs[i] = exec(ctx, task, itemScope)
+		}(i, item)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// copyScope copies scope, including its "tasks" entry one level deep, so
+// that a snapshot handed to a running task is immune to the "tasks" map
+// being mutated by other goroutines as later tasks complete.
+func copyScope(scope map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(scope))
+	for k, v := range scope {
+		if k == "tasks" {
+			if tasks, ok := v.(map[string]interface{}); ok {
+				tasksCopy := make(map[string]interface{}, len(tasks))
+				for name, result := range tasks {
+					tasksCopy[name] = result
+				}
+				out[k] = tasksCopy
+				continue
+			}
+		}
+		out[k] = v
+	}
+	return out
+}
+
+func checkAcyclic(deps map[string][]string) error {
+	const (
+		unvisited = 0
+		visiting  = 1
+		visited   = 2
+	)
+	state := make(map[string]int, len(deps))
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return ErrDependencyCycle
+		}
+		state[name] = visiting
+		for _, dep := range deps[name] {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
+
+	for name := range deps {
+		if err := visit(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
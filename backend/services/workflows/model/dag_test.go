@@ -0,0 +1,190 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunDAGSkipsTaskWhenConditionIsFalse(t *testing.T) {
+	workflow := &Workflow{
+		Name:           "fanout_workflow",
+		MaxConcurrency: 2,
+		Tasks: []Task{
+			{Name: "check_device", Type: TaskTypeCLI, CLI: &CLITask{Command: []string{"true"}}},
+			{
+				Name: "notify_admin", Type: TaskTypeCLI, CLI: &CLITask{Command: []string{"true"}},
+				When: `tasks.check_device.output.status == "failed"`,
+			},
+			{
+				Name: "notify_ok", Type: TaskTypeCLI, CLI: &CLITask{Command: []string{"true"}},
+				When: `tasks.check_device.output.status == "ok"`,
+			},
+		},
+	}
+
+	exec := func(ctx context.Context, task Task, scope map[string]interface{}) (interface{}, error) {
+		if task.Name == "check_device" {
+			return map[string]interface{}{"status": "failed"}, nil
+		}
+		return "done", nil
+	}
+
+	results, err := RunDAG(context.Background(), workflow, nil, exec)
+	assert.NoError(t, err)
+
+	assert.False(t, results["notify_admin"].Skipped)
+	assert.Equal(t, "done", results["notify_admin"].Output)
+
+	assert.True(t, results["notify_ok"].Skipped)
+	assert.Nil(t, results["notify_ok"].Output)
+}
+
+func TestRunDAGFansOutForEach(t *testing.T) {
+	workflow := &Workflow{
+		Name: "fanout_workflow",
+		Tasks: []Task{
+			{Name: "list_users", Type: TaskTypeCLI, CLI: &CLITask{Command: []string{"true"}}},
+			{
+				Name: "notify_users", Type: TaskTypeCLI, CLI: &CLITask{Command: []string{"true"}},
+				ForEach: &ForEach{
+					Items: "${tasks.list_users.output.users}",
+					As:    "user",
+				},
+			},
+		},
+	}
+
+	var mu sync.Mutex
+	var seen []string
+	exec := func(ctx context.Context, task Task, scope map[string]interface{}) (interface{}, error) {
+		if task.Name == "list_users" {
+			return map[string]interface{}{
+				"users": []interface{}{"alice", "bob"},
+			}, nil
+		}
+		mu.Lock()
+		seen = append(seen, scope["user"].(string))
+		mu.Unlock()
+		return "notified:" + scope["user"].(string), nil
+	}
+
+	results, err := RunDAG(context.Background(), workflow, nil, exec)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"alice", "bob"}, seen)
+	assert.ElementsMatch(t, []interface{}{"notified:alice", "notified:bob"}, results["notify_users"].Results)
+}
+
+func TestRunDAGRejectsUnknownTaskReference(t *testing.T) {
+	workflow := &Workflow{
+		Name: "dangling_reference_workflow",
+		Tasks: []Task{
+			{
+				Name: "notify_admin", Type: TaskTypeCLI, CLI: &CLITask{Command: []string{"true"}},
+				When: "tasks.does_not_exist.output.ok",
+			},
+		},
+	}
+
+	done := make(chan struct{})
+	var err error
+	go func() {
+		_, err = RunDAG(context.Background(), workflow, nil, func(
+			ctx context.Context, task Task, scope map[string]interface{},
+		) (interface{}, error) {
+			return nil, nil
+		})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("RunDAG did not return within timeout - it deadlocked on the dangling reference")
+	}
+	assert.ErrorIs(t, err, ErrUnknownTaskReference)
+}
+
+func TestRunDAGStartsTaskAsSoonAsItsOwnDependencyFinishes(t *testing.T) {
+	workflow := &Workflow{
+		Name:           "uneven_fanout_workflow",
+		MaxConcurrency: 3,
+		Tasks: []Task{
+			{Name: "root", Type: TaskTypeCLI, CLI: &CLITask{Command: []string{"true"}}},
+			{
+				Name: "fast_sibling", Type: TaskTypeCLI, CLI: &CLITask{Command: []string{"true"}},
+				Requires: []string{"${tasks.root.output}"},
+			},
+			{
+				Name: "slow_sibling", Type: TaskTypeCLI, CLI: &CLITask{Command: []string{"true"}},
+				Requires: []string{"${tasks.root.output}"},
+			},
+			{
+				Name: "dependent", Type: TaskTypeCLI, CLI: &CLITask{Command: []string{"true"}},
+				Requires: []string{"${tasks.fast_sibling.output}"},
+			},
+		},
+	}
+
+	var mu sync.Mutex
+	finished := make(map[string]time.Time)
+	exec := func(ctx context.Context, task Task, scope map[string]interface{}) (interface{}, error) {
+		switch task.Name {
+		case "slow_sibling":
+			time.Sleep(200 * time.Millisecond)
+		case "fast_sibling":
+			time.Sleep(10 * time.Millisecond)
+		}
+		mu.Lock()
+		finished[task.Name] = time.Now()
+		mu.Unlock()
+		return "done", nil
+	}
+
+	_, err := RunDAG(context.Background(), workflow, nil, exec)
+	assert.NoError(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+	// dependent only Requires fast_sibling, so it must be scheduled (and,
+	// since its own exec is instant, finish) as soon as fast_sibling
+	// does - without waiting for slow_sibling, an unrelated task it does
+	// not depend on that merely happened to be launched in the same wave.
+	assert.True(t, finished["dependent"].Before(finished["slow_sibling"]),
+		"dependent finished at %s, slow_sibling at %s - dependent waited on an unrelated sibling",
+		finished["dependent"], finished["slow_sibling"])
+}
+
+func TestRunDAGDetectsDependencyCycle(t *testing.T) {
+	workflow := &Workflow{
+		Name: "cyclic_workflow",
+		Tasks: []Task{
+			{Name: "a", Type: TaskTypeCLI, CLI: &CLITask{Command: []string{"true"}}, When: "tasks.b.output"},
+			{Name: "b", Type: TaskTypeCLI, CLI: &CLITask{Command: []string{"true"}}, When: "tasks.a.output"},
+		},
+	}
+
+	_, err := RunDAG(context.Background(), workflow, nil, func(
+		ctx context.Context, task Task, scope map[string]interface{},
+	) (interface{}, error) {
+		return nil, nil
+	})
+	assert.ErrorIs(t, err, ErrDependencyCycle)
+}
@@ -0,0 +1,350 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// EvalExpr evaluates a small CEL-like boolean/value expression against
+// scope, a nested map reachable via dotted identifiers (e.g.
+// "workflow.input.request_id" or "tasks.checkDevice.output.status"). It
+// supports string/number/bool/null literals, dotted identifier lookups,
+// the comparison operators ==, !=, <, <=, >, >=, the logical operators
+// &&, ||, ! and parenthesized grouping - enough to express Task.When and
+// the preconditions referenced by Task.Requires and ForEach.Items,
+// without pulling in a full CEL implementation.
+func EvalExpr(expression string, scope map[string]interface{}) (interface{}, error) {
+	p := &exprParser{tokens: tokenizeExpr(expression)}
+	value, err := p.parseOr(scope)
+	if err != nil {
+		return nil, err
+	}
+	if !p.atEnd() {
+		return nil, fmt.Errorf("model: unexpected token %q in expression %q", p.peek(), expression)
+	}
+	return value, nil
+}
+
+// EvalTemplate evaluates a "${...}" wrapped expression to its underlying
+// value. A template that isn't wrapped in ${...} is returned verbatim, as
+// a literal string, matching how Task.Requires entries have always been
+// interpreted.
+func EvalTemplate(template string, scope map[string]interface{}) (interface{}, error) {
+	trimmed := strings.TrimSpace(template)
+	if !strings.HasPrefix(trimmed, "${") || !strings.HasSuffix(trimmed, "}") {
+		return template, nil
+	}
+	inner := trimmed[2 : len(trimmed)-1]
+	return EvalExpr(inner, scope)
+}
+
+// Truthy reports whether value should be treated as true by When and by
+// the Requires non-empty precondition check.
+func Truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case float64:
+		return v != 0
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	default:
+		return true
+	}
+}
+
+type exprTokenKind int
+
+const (
+	tokenEOF exprTokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenOp
+)
+
+type exprToken struct {
+	kind exprTokenKind
+	text string
+}
+
+func tokenizeExpr(expression string) []exprToken {
+	var tokens []exprToken
+	runes := []rune(expression)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"' || c == '\'':
+			quote := c
+			j := i + 1
+			var sb strings.Builder
+			for j < len(runes) && runes[j] != quote {
+				sb.WriteRune(runes[j])
+				j++
+			}
+			tokens = append(tokens, exprToken{kind: tokenString, text: sb.String()})
+			i = j + 1
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(runes) && (runes[j] >= '0' && runes[j] <= '9' || runes[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, exprToken{kind: tokenNumber, text: string(runes[i:j])})
+			i = j
+		case isIdentStart(c):
+			j := i
+			for j < len(runes) && isIdentPart(runes[j]) {
+				j++
+			}
+			tokens = append(tokens, exprToken{kind: tokenIdent, text: string(runes[i:j])})
+			i = j
+		default:
+			two := ""
+			if i+1 < len(runes) {
+				two = string(runes[i : i+2])
+			}
+			switch two {
+			case "==", "!=", "<=", ">=", "&&", "||":
+				tokens = append(tokens, exprToken{kind: tokenOp, text: two})
+				i += 2
+				continue
+			}
+			tokens = append(tokens, exprToken{kind: tokenOp, text: string(c)})
+			i++
+		}
+	}
+	return tokens
+}
+
+func isIdentStart(c rune) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c rune) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9') || c == '.' || c == '-'
+}
+
+// exprParser is a small recursive-descent parser/evaluator in one pass:
+// each parse* method both consumes tokens and returns the evaluated value,
+// since the grammar is too small to benefit from a separate AST.
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+}
+
+func (p *exprParser) peek() string {
+	if p.atEnd() {
+		return ""
+	}
+	return p.tokens[p.pos].text
+}
+
+func (p *exprParser) atEnd() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *exprParser) next() exprToken {
+	t := p.tokens[p.pos]
+	p.pos++
+	return t
+}
+
+// parseOr and parseAnd must still consume tokens for an operand they
+// don't need to evaluate - When/Requires routinely probe task output that
+// may be absent (the task was skipped, or hasn't set that field), e.g.
+// "tasks.x.output.ok && tasks.x.output.count > 0" - so once a short
+// circuit fires, the remaining operand is walked via skip, which consumes
+// its tokens without requiring its value or type to check out, instead of
+// being fully evaluated and rejected for a mismatch the short circuit
+// already made irrelevant.
+func (p *exprParser) parseOr(scope map[string]interface{}) (interface{}, error) {
+	left, err := p.parseAnd(scope)
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" {
+		p.next()
+		if Truthy(left) {
+			p.skip(p.parseAnd)
+			left = true
+			continue
+		}
+		right, err := p.parseAnd(scope)
+		if err != nil {
+			return nil, err
+		}
+		left = Truthy(right)
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd(scope map[string]interface{}) (interface{}, error) {
+	left, err := p.parseEquality(scope)
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&&" {
+		p.next()
+		if !Truthy(left) {
+			p.skip(p.parseEquality)
+			left = false
+			continue
+		}
+		right, err := p.parseEquality(scope)
+		if err != nil {
+			return nil, err
+		}
+		left = Truthy(right)
+	}
+	return left, nil
+}
+
+// skip consumes the tokens belonging to a short-circuited operand by
+// parsing it against an empty scope and discarding both the value and any
+// evaluation error (a missing path or a type mismatch in the unneeded
+// operand must not fail an expression the short circuit already decided).
+func (p *exprParser) skip(parse func(map[string]interface{}) (interface{}, error)) {
+	_, _ = parse(map[string]interface{}{})
+}
+
+func (p *exprParser) parseEquality(scope map[string]interface{}) (interface{}, error) {
+	left, err := p.parseUnary(scope)
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "==" || p.peek() == "!=" || p.peek() == "<" ||
+		p.peek() == "<=" || p.peek() == ">" || p.peek() == ">=" {
+		op := p.next().text
+		right, err := p.parseUnary(scope)
+		if err != nil {
+			return nil, err
+		}
+		left, err = compareValues(op, left, right)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary(scope map[string]interface{}) (interface{}, error) {
+	if p.peek() == "!" {
+		p.next()
+		v, err := p.parseUnary(scope)
+		if err != nil {
+			return nil, err
+		}
+		return !Truthy(v), nil
+	}
+	return p.parsePrimary(scope)
+}
+
+func (p *exprParser) parsePrimary(scope map[string]interface{}) (interface{}, error) {
+	if p.atEnd() {
+		return nil, fmt.Errorf("model: unexpected end of expression")
+	}
+	tok := p.next()
+	switch tok.kind {
+	case tokenString:
+		return tok.text, nil
+	case tokenNumber:
+		n, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("model: invalid number %q: %w", tok.text, err)
+		}
+		return n, nil
+	case tokenIdent:
+		switch tok.text {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		default:
+			return lookupPath(scope, tok.text), nil
+		}
+	case tokenOp:
+		if tok.text == "(" {
+			v, err := p.parseOr(scope)
+			if err != nil {
+				return nil, err
+			}
+			if p.peek() != ")" {
+				return nil, fmt.Errorf("model: missing closing parenthesis")
+			}
+			p.next()
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("model: unexpected token %q", tok.text)
+}
+
+// lookupPath resolves a dotted identifier against nested maps, returning
+// nil if any segment is missing.
+func lookupPath(scope map[string]interface{}, path string) interface{} {
+	var current interface{} = scope
+	for _, segment := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current, ok = m[segment]
+		if !ok {
+			return nil
+		}
+	}
+	return current
+}
+
+func compareValues(op string, left, right interface{}) (interface{}, error) {
+	switch op {
+	case "==":
+		return valuesEqual(left, right), nil
+	case "!=":
+		return !valuesEqual(left, right), nil
+	}
+	lf, lok := left.(float64)
+	rf, rok := right.(float64)
+	if !lok || !rok {
+		return nil, fmt.Errorf("model: operator %q requires numeric operands", op)
+	}
+	switch op {
+	case "<":
+		return lf < rf, nil
+	case "<=":
+		return lf <= rf, nil
+	case ">":
+		return lf > rf, nil
+	case ">=":
+		return lf >= rf, nil
+	}
+	return nil, fmt.Errorf("model: unknown operator %q", op)
+}
+
+func valuesEqual(left, right interface{}) bool {
+	return fmt.Sprintf("%v", left) == fmt.Sprintf("%v", right)
+}
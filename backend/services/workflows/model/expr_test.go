@@ -0,0 +1,93 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func exprTestScope() map[string]interface{} {
+	return map[string]interface{}{
+		"workflow": map[string]interface{}{
+			"input": map[string]interface{}{"device_id": "abc123"},
+		},
+		"tasks": map[string]interface{}{
+			"check_device": map[string]interface{}{
+				"output": map[string]interface{}{
+					"status": "failed",
+					"count":  float64(3),
+				},
+			},
+		},
+	}
+}
+
+func TestEvalExprComparisons(t *testing.T) {
+	scope := exprTestScope()
+
+	v, err := EvalExpr(`tasks.check_device.output.status == "failed"`, scope)
+	assert.NoError(t, err)
+	assert.Equal(t, true, v)
+
+	v, err = EvalExpr(`tasks.check_device.output.count > 1 && tasks.check_device.output.count < 5`, scope)
+	assert.NoError(t, err)
+	assert.Equal(t, true, v)
+
+	v, err = EvalExpr(`workflow.input.device_id == "other" || tasks.check_device.output.status == "failed"`, scope)
+	assert.NoError(t, err)
+	assert.Equal(t, true, v)
+
+	v, err = EvalExpr(`!(tasks.check_device.output.status == "failed")`, scope)
+	assert.NoError(t, err)
+	assert.Equal(t, false, v)
+}
+
+func TestEvalExprMissingPathIsNil(t *testing.T) {
+	scope := exprTestScope()
+	v, err := EvalExpr("tasks.does_not_exist.output.status", scope)
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+	assert.False(t, Truthy(v))
+}
+
+func TestEvalExprShortCircuits(t *testing.T) {
+	scope := exprTestScope()
+
+	// The right operand references a path that isn't a number, so
+	// evaluating it would fail the numeric-operand check in compareValues;
+	// && must short-circuit to false without evaluating it.
+	v, err := EvalExpr(`tasks.missing.output.ok && tasks.missing.output.count > 0`, scope)
+	assert.NoError(t, err)
+	assert.Equal(t, false, v)
+
+	// Same for || once the left side is already true.
+	v, err = EvalExpr(`workflow.input.device_id == "abc123" || tasks.missing.output.count > 0`, scope)
+	assert.NoError(t, err)
+	assert.Equal(t, true, v)
+}
+
+func TestEvalTemplate(t *testing.T) {
+	scope := exprTestScope()
+
+	v, err := EvalTemplate("${workflow.input.device_id}", scope)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc123", v)
+
+	v, err = EvalTemplate("plain-string", scope)
+	assert.NoError(t, err)
+	assert.Equal(t, "plain-string", v)
+}
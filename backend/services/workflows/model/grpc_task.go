@@ -0,0 +1,53 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+// TaskTypeGRPC invokes a unary RPC on another Mender microservice, using
+// either a compiled FileDescriptorSet referenced by Proto or server
+// reflection when Proto is empty.
+const TaskTypeGRPC TaskType = "grpc"
+
+// GRPCTask is the definition of a TaskTypeGRPC task.
+type GRPCTask struct {
+	// Target is the gRPC server address, e.g. "mender-deviceauth:9000".
+	Target  string `json:"target"`
+	Service string `json:"service"`
+	Method  string `json:"method"`
+
+	// Proto optionally points at a FileDescriptorSet to invoke the
+	// method without relying on server reflection.
+	Proto *ProtoRef `json:"proto,omitempty"`
+
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Body     interface{}       `json:"body,omitempty"`
+
+	TimeoutMs int `json:"timeoutMs,omitempty"`
+
+	TLS *TLSConfig `json:"tls,omitempty"`
+}
+
+// ProtoRef points at a protobuf FileDescriptorSet, either embedded inline
+// (base64-encoded) or by filesystem path.
+type ProtoRef struct {
+	Inline string `json:"inline,omitempty"`
+	File   string `json:"file,omitempty"`
+}
+
+// TLSConfig configures transport security for a GRPCTask.
+type TLSConfig struct {
+	Enabled            bool   `json:"enabled,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+	CAFile             string `json:"caFile,omitempty"`
+}
@@ -0,0 +1,32 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+// TaskTypeNATS publishes (and optionally request/replies) on the NATS
+// connection already used internally for job delivery, letting a workflow
+// notify other services without an HTTP bridge.
+const TaskTypeNATS TaskType = "nats"
+
+// NATSTask is the definition of a TaskTypeNATS task.
+type NATSTask struct {
+	Subject string `json:"subject"`
+	Payload string `json:"payload"`
+
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// ReplyTimeoutMs, when non-zero, turns the publish into a
+	// request/reply call and bounds how long to wait for the reply.
+	ReplyTimeoutMs int `json:"replyTimeoutMs,omitempty"`
+}
@@ -0,0 +1,118 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+)
+
+var (
+	ErrWorkflowInvalid             = errors.New("model: workflow definition is invalid")
+	ErrWorkflowSchemaVersionChange = errors.New(
+		"model: update would change schemaVersion of an existing workflow",
+	)
+)
+
+// WorkflowRegistry is a thread-safe, in-memory workflow store keyed by
+// workflow name. It is meant to be kept current by WatchWorkflowsFromPath
+// via Watch, so the worker and HTTP layer always dispatch against the
+// latest workflow definitions without restarting the service.
+type WorkflowRegistry struct {
+	mu        sync.RWMutex
+	workflows map[string]*Workflow
+}
+
+// NewWorkflowRegistry returns an empty WorkflowRegistry.
+func NewWorkflowRegistry() *WorkflowRegistry {
+	return &WorkflowRegistry{
+		workflows: make(map[string]*Workflow),
+	}
+}
+
+// Get returns the current workflow definition for name, if any.
+func (r *WorkflowRegistry) Get(name string) (*Workflow, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	workflow, ok := r.workflows[name]
+	return workflow, ok
+}
+
+// List returns a snapshot of all currently registered workflows.
+func (r *WorkflowRegistry) List() []*Workflow {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	workflows := make([]*Workflow, 0, len(r.workflows))
+	for _, workflow := range r.workflows {
+		workflows = append(workflows, workflow)
+	}
+	return workflows
+}
+
+// Apply validates and applies a single WorkflowEvent, rejecting a
+// WorkflowEventAdded/WorkflowEventUpdated event whose workflow is invalid,
+// or whose schemaVersion differs from the currently registered workflow of
+// the same name.
+func (r *WorkflowRegistry) Apply(event WorkflowEvent) error {
+	if event.Type == WorkflowEventRemoved {
+		r.mu.Lock()
+		delete(r.workflows, event.Name)
+		r.mu.Unlock()
+		return nil
+	}
+
+	if event.Workflow == nil || event.Workflow.Name == "" {
+		return ErrWorkflowInvalid
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, ok := r.workflows[event.Name]; ok &&
+		existing.SchemaVersion != event.Workflow.SchemaVersion {
+		return ErrWorkflowSchemaVersionChange
+	}
+	r.workflows[event.Name] = event.Workflow
+	return nil
+}
+
+// Watch seeds the registry from the workflow definitions already present
+// in dir, then applies every subsequent WatchWorkflowsFromPath event to the
+// registry until ctx is canceled. Rejected updates are logged, not
+// returned, since a single malformed file must not stop the registry from
+// tracking the rest of the directory.
+func (r *WorkflowRegistry) Watch(ctx context.Context, dir string) error {
+	for name, workflow := range GetWorkflowsFromPath(dir) {
+		if err := r.Apply(WorkflowEvent{
+			Type: WorkflowEventAdded, Name: name, Workflow: workflow,
+		}); err != nil {
+			log.Printf("workflow registry: rejected %q: %s", name, err)
+		}
+	}
+
+	events, err := WatchWorkflowsFromPath(ctx, dir)
+	if err != nil {
+		return err
+	}
+	go func() {
+		for event := range events {
+			if err := r.Apply(event); err != nil {
+				log.Printf("workflow registry: rejected update for %q: %s", event.Name, err)
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const registryConvergeTimeout = 5 * time.Second
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(registryConvergeTimeout)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("timed out waiting for workflow registry to converge")
+}
+
+func TestWorkflowRegistryWatch(t *testing.T) {
+	dir := t.TempDir()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	registry := NewWorkflowRegistry()
+	err := registry.Watch(ctx, dir)
+	assert.NoError(t, err)
+
+	decommission := filepath.Join(dir, "decommission.json")
+	err = os.WriteFile(decommission, []byte(`{
+		"name": "decommission_device",
+		"version": 1,
+		"schemaVersion": 1,
+		"tasks": [{"name": "noop", "type": "cli", "cli": {"command": ["true"]}}]
+	}`), 0666)
+	assert.NoError(t, err)
+
+	waitFor(t, func() bool {
+		_, ok := registry.Get("decommission_device")
+		return ok
+	})
+
+	err = os.WriteFile(decommission, []byte(`{
+		"name": "decommission_device",
+		"version": 2,
+		"schemaVersion": 1,
+		"tasks": [{"name": "noop", "type": "cli", "cli": {"command": ["true"]}}]
+	}`), 0666)
+	assert.NoError(t, err)
+
+	waitFor(t, func() bool {
+		workflow, ok := registry.Get("decommission_device")
+		return ok && workflow.Version == 2
+	})
+
+	err = os.Remove(decommission)
+	assert.NoError(t, err)
+
+	waitFor(t, func() bool {
+		_, ok := registry.Get("decommission_device")
+		return !ok
+	})
+}
+
+func TestWorkflowRegistryApplyRejectsSchemaVersionChange(t *testing.T) {
+	registry := NewWorkflowRegistry()
+
+	err := registry.Apply(WorkflowEvent{
+		Type: WorkflowEventAdded,
+		Name: "provision_device",
+		Workflow: &Workflow{
+			Name: "provision_device", SchemaVersion: 1,
+		},
+	})
+	assert.NoError(t, err)
+
+	err = registry.Apply(WorkflowEvent{
+		Type: WorkflowEventUpdated,
+		Name: "provision_device",
+		Workflow: &Workflow{
+			Name: "provision_device", SchemaVersion: 2,
+		},
+	})
+	assert.ErrorIs(t, err, ErrWorkflowSchemaVersionChange)
+
+	workflow, ok := registry.Get("provision_device")
+	assert.True(t, ok)
+	assert.Equal(t, 1, workflow.SchemaVersion)
+}
@@ -0,0 +1,117 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"time"
+)
+
+const (
+	DefaultRetryInitialBackoffMs = 1000
+	DefaultRetryMaxBackoffMs     = 30000
+	DefaultRetryMultiplier       = 2.0
+	DefaultRetryJitterPct        = 0.2
+)
+
+// RetryPolicy configures how a failed task is retried. It unmarshals from
+// either a bare integer - the legacy "retries: 3" shorthand, expanded to
+// the package defaults below - or a full JSON/YAML object.
+type RetryPolicy struct {
+	MaxAttempts int `json:"maxAttempts"`
+
+	InitialBackoffMs int     `json:"initialBackoffMs,omitempty"`
+	MaxBackoffMs     int     `json:"maxBackoffMs,omitempty"`
+	Multiplier       float64 `json:"multiplier,omitempty"`
+	JitterPct        float64 `json:"jitterPct,omitempty"`
+
+	RetryOnStatusCodes  []int `json:"retryOnStatusCodes,omitempty"`
+	RetryOnNetworkError bool  `json:"retryOnNetworkError,omitempty"`
+}
+
+// defaultRetryPolicy expands the bare-integer shorthand into a full policy.
+func defaultRetryPolicy(maxAttempts int) RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: maxAttempts,
+
+		InitialBackoffMs: DefaultRetryInitialBackoffMs,
+		MaxBackoffMs:     DefaultRetryMaxBackoffMs,
+		Multiplier:       DefaultRetryMultiplier,
+		JitterPct:        DefaultRetryJitterPct,
+
+		RetryOnNetworkError: true,
+	}
+}
+
+// UnmarshalJSON accepts both the legacy bare-integer shorthand and a full
+// RetryPolicy object.
+func (r *RetryPolicy) UnmarshalJSON(data []byte) error {
+	var maxAttempts int
+	if err := json.Unmarshal(data, &maxAttempts); err == nil {
+		*r = defaultRetryPolicy(maxAttempts)
+		return nil
+	}
+
+	type plain RetryPolicy
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*r = RetryPolicy(p)
+	return nil
+}
+
+// Backoff computes the delay before retry attempt n (1-indexed), as
+// min(initial * multiplier^(n-1), max), jittered by ±JitterPct.
+func (r RetryPolicy) Backoff(attempt int) time.Duration {
+	multiplier := r.Multiplier
+	if multiplier <= 0 {
+		multiplier = DefaultRetryMultiplier
+	}
+	initial := r.InitialBackoffMs
+	if initial <= 0 {
+		initial = DefaultRetryInitialBackoffMs
+	}
+	max := r.MaxBackoffMs
+	if max <= 0 {
+		max = DefaultRetryMaxBackoffMs
+	}
+
+	backoffMs := float64(initial) * math.Pow(multiplier, float64(attempt-1))
+	if backoffMs > float64(max) {
+		backoffMs = float64(max)
+	}
+
+	jitterPct := r.JitterPct
+	if jitterPct > 0 {
+		jitter := (rand.Float64()*2 - 1) * jitterPct
+		backoffMs += backoffMs * jitter
+		if backoffMs < 0 {
+			backoffMs = 0
+		}
+	}
+	return time.Duration(backoffMs) * time.Millisecond
+}
+
+// CircuitBreaker bounds how many consecutive task failures are tolerated
+// before the worker stops dispatching a task for OpenDurationMs, to keep a
+// partial downstream outage from being hammered by immediate retries.
+type CircuitBreaker struct {
+	FailureThreshold int `json:"failureThreshold"`
+	OpenDurationMs   int `json:"openDurationMs"`
+	HalfOpenMaxCalls int `json:"halfOpenMaxCalls,omitempty"`
+}
@@ -0,0 +1,54 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+// TaskTypeScenario chains an ordered list of HTTP steps, with values
+// captured from earlier steps available to later ones via the usual
+// ${workflow.xxx} template syntax under the "scenario" namespace (e.g.
+// ${scenario.access_token}). This lets a single workflow implement OAuth
+// flows, paginated API walks, or multi-call device provisioning without
+// defining one workflow per call.
+const TaskTypeScenario TaskType = "scenario"
+
+// ScenarioTask is the definition of a TaskTypeScenario task.
+type ScenarioTask struct {
+	// Preprocessors maps a scenario variable name to the expression used
+	// to compute its initial value: "random_string(n)", "now()",
+	// "uuid()", or "input.<name>" to copy a workflow input parameter.
+	Preprocessors map[string]string `json:"preprocessors,omitempty"`
+
+	Steps []ScenarioStep `json:"steps"`
+}
+
+// ScenarioStep is a single request within a ScenarioTask.
+type ScenarioStep struct {
+	Name string    `json:"name"`
+	HTTP *HTTPTask `json:"http"`
+
+	// Postprocessors extract values from this step's response into
+	// scenario variables available to subsequent steps.
+	Postprocessors []Postprocessor `json:"postprocessors,omitempty"`
+}
+
+// Postprocessor extracts a single value from an HTTP response into a
+// scenario variable named As. Exactly one of JSONPath, Header, or Regex
+// should be set.
+type Postprocessor struct {
+	JSONPath string `json:"jsonpath,omitempty"`
+	Header   string `json:"header,omitempty"`
+	Regex    string `json:"regex,omitempty"`
+
+	As string `json:"as"`
+}
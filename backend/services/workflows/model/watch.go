@@ -0,0 +1,131 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// WorkflowEventType identifies the change a WorkflowEvent reports.
+type WorkflowEventType string
+
+const (
+	WorkflowEventAdded   WorkflowEventType = "added"
+	WorkflowEventUpdated WorkflowEventType = "updated"
+	WorkflowEventRemoved WorkflowEventType = "removed"
+)
+
+// WorkflowEvent reports that a workflow definition file was added, updated,
+// or removed from a directory watched by WatchWorkflowsFromPath. Workflow
+// is nil for WorkflowEventRemoved.
+type WorkflowEvent struct {
+	Type     WorkflowEventType
+	Name     string
+	Workflow *Workflow
+}
+
+func isWorkflowFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".yaml", ".yml":
+		return true
+	default:
+		return false
+	}
+}
+
+func parseWorkflowFileByExt(path string) (*Workflow, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return parseWorkflowFile(path, ParseWorkflowFromJSON)
+	default:
+		return parseWorkflowFile(path, parseWorkflowFromYAML)
+	}
+}
+
+// WatchWorkflowsFromPath watches dir for workflow definition files being
+// added, changed, or removed, and emits a WorkflowEvent for each, so that
+// operators can drop new workflow files into a mounted volume (a ConfigMap,
+// in the common Kubernetes case) without restarting the workflows service.
+// The returned channel is closed when ctx is canceled.
+func WatchWorkflowsFromPath(ctx context.Context, dir string) (<-chan WorkflowEvent, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(dir); err != nil {
+		_ = watcher.Close()
+		return nil, err
+	}
+
+	events := make(chan WorkflowEvent)
+	go func() {
+		defer watcher.Close()
+		defer close(events)
+
+		// names tracks which workflow name was last parsed from a given
+		// file path, since a Removed fsnotify event only carries the
+		// path, not the workflow name inside the deleted file.
+		names := make(map[string]string)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !isWorkflowFile(ev.Name) {
+					continue
+				}
+				switch {
+				case ev.Op&(fsnotify.Remove|fsnotify.Rename) != 0:
+					name, tracked := names[ev.Name]
+					if !tracked {
+						continue
+					}
+					delete(names, ev.Name)
+					events <- WorkflowEvent{Type: WorkflowEventRemoved, Name: name}
+
+				case ev.Op&(fsnotify.Write|fsnotify.Create) != 0:
+					workflow, err := parseWorkflowFileByExt(ev.Name)
+					if err != nil {
+						log.Printf("workflow watch: failed to parse %s: %s", ev.Name, err)
+						continue
+					}
+					_, existed := names[ev.Name]
+					names[ev.Name] = workflow.Name
+					eventType := WorkflowEventAdded
+					if existed {
+						eventType = WorkflowEventUpdated
+					}
+					events <- WorkflowEvent{
+						Type: eventType, Name: workflow.Name, Workflow: workflow,
+					}
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("workflow watch: %s", err)
+			}
+		}
+	}()
+	return events, nil
+}
@@ -0,0 +1,177 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// TaskType identifies which of the task kind-specific fields on Task is
+// populated.
+type TaskType string
+
+const (
+	TaskTypeHTTP TaskType = "http"
+	TaskTypeCLI  TaskType = "cli"
+)
+
+// Workflow is the in-memory representation of a workflow definition loaded
+// from JSON or YAML, as consumed by the worker to drive task execution.
+type Workflow struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Version     int    `json:"version"`
+
+	Tasks []Task `json:"tasks"`
+
+	InputParameters []string `json:"inputParameters"`
+
+	SchemaVersion int `json:"schemaVersion"`
+
+	// CircuitBreakers configures a circuit breaker per task name; tasks
+	// without an entry here are never tripped open.
+	CircuitBreakers map[string]CircuitBreaker `json:"circuitBreakers,omitempty"`
+
+	// MaxConcurrency bounds how many tasks RunDAG schedules at once. Zero
+	// means the bound is the number of tasks in the workflow; ForEach
+	// elements count against the same bound.
+	MaxConcurrency int `json:"maxConcurrency,omitempty"`
+}
+
+// Task describes a single step of a Workflow.
+type Task struct {
+	Name string   `json:"name"`
+	Type TaskType `json:"type"`
+
+	HTTP     *HTTPTask     `json:"http,omitempty"`
+	CLI      *CLITask      `json:"cli,omitempty"`
+	Scenario *ScenarioTask `json:"scenario,omitempty"`
+	GRPC     *GRPCTask     `json:"grpc,omitempty"`
+	NATS     *NATSTask     `json:"nats,omitempty"`
+
+	// Requires lists template expressions (e.g.
+	// "${workflow.input.request_id}") that must resolve to a non-empty
+	// value before the task is scheduled.
+	Requires []string `json:"requires,omitempty"`
+
+	// Retries accepts either the legacy bare-integer shorthand or a full
+	// RetryPolicy object; see RetryPolicy.UnmarshalJSON.
+	Retries *RetryPolicy `json:"retries,omitempty"`
+
+	// When is a CEL-like expression (see EvalExpr) evaluated against the
+	// workflow inputs and prior task outputs; a task whose When evaluates
+	// to false is skipped rather than executed. Empty means always run.
+	When string `json:"when,omitempty"`
+
+	// ForEach, if set, fans the task out across a collection instead of
+	// running it once.
+	ForEach *ForEach `json:"foreach,omitempty"`
+}
+
+// HTTPTask is the definition of a TaskTypeHTTP task.
+type HTTPTask struct {
+	URI    string `json:"uri"`
+	Method string `json:"method"`
+
+	ContentType string                 `json:"contentType,omitempty"`
+	Body        string                 `json:"body,omitempty"`
+	FormData    map[string]string      `json:"formdata,omitempty"`
+	JSON        map[string]interface{} `json:"json,omitempty"`
+	Headers     map[string]string      `json:"headers,omitempty"`
+
+	StatusCodes []int `json:"statusCodes,omitempty"`
+
+	ConnectionTimeOut int `json:"connectionTimeOut,omitempty"`
+	ReadTimeOut       int `json:"readTimeOut,omitempty"`
+}
+
+// CLITask is the definition of a TaskTypeCLI task.
+type CLITask struct {
+	Command          []string `json:"command"`
+	ExecutionTimeOut int      `json:"executionTimeOut,omitempty"`
+}
+
+// ParseWorkflowFromJSON parses a single workflow definition from JSON.
+func ParseWorkflowFromJSON(data []byte) (*Workflow, error) {
+	var workflow Workflow
+	if err := json.Unmarshal(data, &workflow); err != nil {
+		return nil, err
+	}
+	return &workflow, nil
+}
+
+// parseWorkflowFromYAML parses a single workflow definition from YAML,
+// honoring the same field names as ParseWorkflowFromJSON.
+func parseWorkflowFromYAML(data []byte) (*Workflow, error) {
+	var workflow Workflow
+	if err := yaml.Unmarshal(data, &workflow); err != nil {
+		return nil, err
+	}
+	return &workflow, nil
+}
+
+// GetWorkflowsFromPath scans dir (non-recursively) for .json/.yaml/.yml
+// files and parses each as a workflow definition, keyed by workflow name.
+// Files that fail to parse are skipped and logged, not returned as an
+// error, so that one malformed file does not prevent the rest of the
+// directory from loading.
+func GetWorkflowsFromPath(dir string) map[string]*Workflow {
+	workflows := make(map[string]*Workflow)
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return workflows
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		filename := entry.Name()
+		path := filepath.Join(dir, filename)
+
+		var workflow *Workflow
+		switch strings.ToLower(filepath.Ext(filename)) {
+		case ".json":
+			workflow, err = parseWorkflowFile(path, ParseWorkflowFromJSON)
+		case ".yaml", ".yml":
+			workflow, err = parseWorkflowFile(path, parseWorkflowFromYAML)
+		default:
+			continue
+		}
+		if err != nil {
+			log.Printf("failed to parse workflow file %s: %s", path, err)
+			continue
+		}
+		workflows[workflow.Name] = workflow
+	}
+	return workflows
+}
+
+func parseWorkflowFile(
+	path string, parse func([]byte) (*Workflow, error),
+) (*Workflow, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parse(data)
+}
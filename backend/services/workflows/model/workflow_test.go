@@ -20,6 +20,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -178,6 +179,252 @@ func TestParseWorkflowWithCLIFromJSON(t *testing.T) {
 	assert.Equal(t, 1000, cliTask.ExecutionTimeOut)
 }
 
+func TestParseWorkflowWithScenarioFromJSON(t *testing.T) {
+	data := []byte(`
+{
+	"name": "login_and_fetch_device",
+	"description": "Logs in then calls a protected endpoint.",
+	"version": 1,
+	"tasks": [
+		{
+			"name": "login_then_fetch",
+			"type": "scenario",
+			"scenario": {
+				"preprocessors": {
+					"request_id": "uuid()"
+				},
+				"steps": [
+					{
+						"name": "login",
+						"http": {
+							"uri": "http://mender-useradm:8080/api/management/v1/useradm/auth/login",
+							"method": "POST",
+							"headers": {
+								"X-MEN-RequestID": "${scenario.request_id}"
+							}
+						},
+						"postprocessors": [
+							{
+								"jsonpath": "$.token",
+								"as": "access_token"
+							}
+						]
+					},
+					{
+						"name": "fetch_device",
+						"http": {
+							"uri": "http://mender-inventory:8080/api/management/v1/inventory/devices/${workflow.input.device_id}",
+							"method": "GET",
+							"headers": {
+								"Authorization": "Bearer ${scenario.access_token}"
+							}
+						}
+					}
+				]
+			}
+		}
+	],
+	"inputParameters": [
+		"device_id"
+	],
+	"schemaVersion": 1
+}`)
+
+	var workflow, err = ParseWorkflowFromJSON(data)
+	assert.Nil(t, err)
+	assert.NotNil(t, workflow)
+
+	var tasks = workflow.Tasks
+	assert.Len(t, tasks, 1)
+	assert.Equal(t, TaskTypeScenario, tasks[0].Type)
+
+	var scenario = tasks[0].Scenario
+	assert.Equal(t, "uuid()", scenario.Preprocessors["request_id"])
+	assert.Len(t, scenario.Steps, 2)
+
+	assert.Equal(t, "login", scenario.Steps[0].Name)
+	assert.Equal(t, "${scenario.request_id}", scenario.Steps[0].HTTP.Headers["X-MEN-RequestID"])
+	assert.Len(t, scenario.Steps[0].Postprocessors, 1)
+	assert.Equal(t, "$.token", scenario.Steps[0].Postprocessors[0].JSONPath)
+	assert.Equal(t, "access_token", scenario.Steps[0].Postprocessors[0].As)
+
+	assert.Equal(t, "fetch_device", scenario.Steps[1].Name)
+	assert.Equal(t,
+		"Bearer ${scenario.access_token}",
+		scenario.Steps[1].HTTP.Headers["Authorization"],
+	)
+}
+
+func TestParseWorkflowWithGRPCAndNATSFromJSON(t *testing.T) {
+	data := []byte(`
+{
+	"name": "notify_other_services",
+	"description": "Call a gRPC service then publish a NATS notification.",
+	"version": 1,
+	"tasks": [
+		{
+			"name": "check_device",
+			"type": "grpc",
+			"grpc": {
+				"target": "mender-deviceauth:9000",
+				"service": "deviceauth.DeviceAuthService",
+				"method": "CheckDevice",
+				"metadata": {
+					"X-MEN-RequestID": "${workflow.input.request_id}"
+				},
+				"timeoutMs": 2000
+			}
+		},
+		{
+			"name": "publish_event",
+			"type": "nats",
+			"nats": {
+				"subject": "device.provisioned",
+				"payload": "${workflow.input.device_id}",
+				"replyTimeoutMs": 1000
+			}
+		}
+	],
+	"schemaVersion": 1
+}`)
+
+	var workflow, err = ParseWorkflowFromJSON(data)
+	assert.Nil(t, err)
+	assert.NotNil(t, workflow)
+
+	var tasks = workflow.Tasks
+	assert.Len(t, tasks, 2)
+
+	assert.Equal(t, TaskTypeGRPC, tasks[0].Type)
+	assert.Equal(t, "mender-deviceauth:9000", tasks[0].GRPC.Target)
+	assert.Equal(t, "deviceauth.DeviceAuthService", tasks[0].GRPC.Service)
+	assert.Equal(t, "CheckDevice", tasks[0].GRPC.Method)
+	assert.Equal(t, 2000, tasks[0].GRPC.TimeoutMs)
+
+	assert.Equal(t, TaskTypeNATS, tasks[1].Type)
+	assert.Equal(t, "device.provisioned", tasks[1].NATS.Subject)
+	assert.Equal(t, "${workflow.input.device_id}", tasks[1].NATS.Payload)
+	assert.Equal(t, 1000, tasks[1].NATS.ReplyTimeoutMs)
+}
+
+func TestParseWorkflowWithRetryPolicyFromJSON(t *testing.T) {
+	data := []byte(`
+{
+	"name": "retry_policy_workflow",
+	"version": 1,
+	"tasks": [
+		{
+			"name": "shorthand",
+			"type": "cli",
+			"cli": {"command": ["true"]},
+			"retries": 3
+		},
+		{
+			"name": "full_policy",
+			"type": "cli",
+			"cli": {"command": ["true"]},
+			"retries": {
+				"maxAttempts": 5,
+				"initialBackoffMs": 200,
+				"maxBackoffMs": 5000,
+				"multiplier": 1.5,
+				"jitterPct": 0.1,
+				"retryOnStatusCodes": [502, 503],
+				"retryOnNetworkError": true
+			}
+		}
+	],
+	"circuitBreakers": {
+		"full_policy": {
+			"failureThreshold": 5,
+			"openDurationMs": 10000
+		}
+	},
+	"schemaVersion": 1
+}`)
+
+	var workflow, err = ParseWorkflowFromJSON(data)
+	assert.Nil(t, err)
+	assert.NotNil(t, workflow)
+
+	shorthand := workflow.Tasks[0].Retries
+	assert.Equal(t, 3, shorthand.MaxAttempts)
+	assert.Equal(t, DefaultRetryInitialBackoffMs, shorthand.InitialBackoffMs)
+	assert.Equal(t, DefaultRetryMultiplier, shorthand.Multiplier)
+	assert.True(t, shorthand.RetryOnNetworkError)
+
+	full := workflow.Tasks[1].Retries
+	assert.Equal(t, 5, full.MaxAttempts)
+	assert.Equal(t, 200, full.InitialBackoffMs)
+	assert.Equal(t, []int{502, 503}, full.RetryOnStatusCodes)
+
+	breaker, ok := workflow.CircuitBreakers["full_policy"]
+	assert.True(t, ok)
+	assert.Equal(t, 5, breaker.FailureThreshold)
+	assert.Equal(t, 10000, breaker.OpenDurationMs)
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts:      5,
+		InitialBackoffMs: 1000,
+		MaxBackoffMs:     5000,
+		Multiplier:       2,
+	}
+	assert.Equal(t, 1000*time.Millisecond, policy.Backoff(1))
+	assert.Equal(t, 2000*time.Millisecond, policy.Backoff(2))
+	assert.Equal(t, 4000*time.Millisecond, policy.Backoff(3))
+	// Capped at MaxBackoffMs.
+	assert.Equal(t, 5000*time.Millisecond, policy.Backoff(4))
+}
+
+func TestParseWorkflowWithWhenAndForEachFromJSON(t *testing.T) {
+	data := []byte(`
+{
+	"name": "fanout_workflow",
+	"version": 1,
+	"maxConcurrency": 4,
+	"tasks": [
+		{
+			"name": "check_device",
+			"type": "cli",
+			"cli": {"command": ["true"]}
+		},
+		{
+			"name": "notify_admin",
+			"type": "cli",
+			"cli": {"command": ["true"]},
+			"when": "tasks.check_device.output.status == \"failed\""
+		},
+		{
+			"name": "notify_users",
+			"type": "cli",
+			"cli": {"command": ["true"]},
+			"foreach": {
+				"items": "${tasks.check_device.output.users}",
+				"as": "user",
+				"maxParallel": 2
+			}
+		}
+	],
+	"schemaVersion": 1
+}`)
+
+	workflow, err := ParseWorkflowFromJSON(data)
+	assert.Nil(t, err)
+	assert.NotNil(t, workflow)
+	assert.Equal(t, 4, workflow.MaxConcurrency)
+
+	notify := workflow.Tasks[1]
+	assert.Equal(t, `tasks.check_device.output.status == "failed"`, notify.When)
+
+	fanout := workflow.Tasks[2]
+	assert.NotNil(t, fanout.ForEach)
+	assert.Equal(t, "${tasks.check_device.output.users}", fanout.ForEach.Items)
+	assert.Equal(t, "user", fanout.ForEach.As)
+	assert.Equal(t, 2, fanout.ForEach.MaxParallel)
+}
+
 func TestParseWorkflowFromInvalidJSON(t *testing.T) {
 	data := []byte(`INVALID JSON`)
 